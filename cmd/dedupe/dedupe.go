@@ -0,0 +1,139 @@
+package dedupe
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+const (
+	FlagKeep   = "keep"
+	FlagDryRun = "dry-run"
+)
+
+// Deduper interface that allows you to merge the duplicate pages of the notion database
+type Deduper interface {
+	PlanDedupe(ctx context.Context, databaseID string, strategy syncer.KeepStrategy) (*syncer.DedupePlan, error)
+	Dedupe(ctx context.Context, plan *syncer.DedupePlan) (*syncer.SyncResult, error)
+}
+
+// DeduperInitializer function provides a way to initialize the deduper with the given options
+type DeduperInitializer func(flags sync.Flags) (Deduper, error)
+
+var (
+	ErrDeduperInitializerRequired = errors.New("deduper initializer is required")
+	ErrInvalidKeep                = errors.New("keep must be one of: oldest, most-content")
+)
+
+// a map of required flags and their respective error. The github token is not required, since only notion is used.
+var requiredFlags = map[string]error{
+	sync.FlagNotionToken:      sync.ErrNotionTokenRequired,
+	sync.FlagNotionDatabaseID: sync.ErrNotionDatabaseIDRequired,
+}
+
+// NewCommand returns a new cobra command that merges the pages of the notion database that hold the same repository
+func NewCommand(initializerFn DeduperInitializer) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Merge the pages of the notion database that hold the same repository, archiving the duplicates",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateRequiredFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, initializerFn)
+		},
+	}
+
+	sync.AddSyncerFlags(command.Flags())
+	command.Flags().String(FlagKeep, string(syncer.KeepOldest), "The page kept for each repository: oldest, or most-content to keep the one with the most content in its body")
+	command.Flags().Bool(FlagDryRun, false, "Print the pages that would be merged and archived, without changing them")
+
+	return command
+}
+
+// validateRequiredFlags validates the flags passed to the dedupe command
+func validateRequiredFlags(flags *pflag.FlagSet) error {
+	for flagName, flagErr := range requiredFlags {
+		flagValue, err := flags.GetString(flagName)
+		if err != nil {
+			return err
+		}
+
+		if flagValue == "" {
+			return flagErr
+		}
+	}
+
+	return nil
+}
+
+// run executes the dedupe command
+func run(cmd *cobra.Command, initializerFn DeduperInitializer) error {
+	if initializerFn == nil {
+		return ErrDeduperInitializerRequired
+	}
+
+	ctx := cmd.Context()
+	flags, err := sync.ParseSyncerFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	keep, err := cmd.Flags().GetString(FlagKeep)
+	if err != nil {
+		return err
+	}
+
+	strategy := syncer.KeepStrategy(keep)
+	if strategy.Validate() != nil {
+		return ErrInvalidKeep
+	}
+
+	dryRun, err := cmd.Flags().GetBool(FlagDryRun)
+	if err != nil {
+		return err
+	}
+
+	deduperSvc, err := initializerFn(flags)
+	if err != nil {
+		return err
+	}
+
+	plan, err := deduperSvc.PlanDedupe(ctx, flags.NotionDatabaseID, strategy)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if err := printPlan(out, plan); err != nil {
+		return err
+	}
+
+	if dryRun || len(plan.Duplicates) == 0 {
+		return nil
+	}
+
+	result, err := deduperSvc.Dedupe(ctx, plan)
+	if result == nil {
+		return err
+	}
+
+	if printErr := printResult(out, result); printErr != nil {
+		return printErr
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	if result.Succeeded() == 0 {
+		return &sync.ExitError{Code: sync.ExitCodeFailure, Err: err}
+	}
+
+	return &sync.ExitError{Code: sync.ExitCodePartialFailure, Err: err}
+}
@@ -0,0 +1,168 @@
+package dedupe_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brpaz/github-stars-notion-sync/cmd/dedupe"
+	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+type MockDeduper struct {
+	mock.Mock
+}
+
+func (m *MockDeduper) PlanDedupe(ctx context.Context, databaseID string, strategy syncer.KeepStrategy) (*syncer.DedupePlan, error) {
+	args := m.Called(ctx, databaseID, strategy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*syncer.DedupePlan), args.Error(1)
+}
+
+func (m *MockDeduper) Dedupe(ctx context.Context, plan *syncer.DedupePlan) (*syncer.SyncResult, error) {
+	args := m.Called(ctx, plan)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+
+	return args.Get(0).(*syncer.SyncResult), args.Error(1)
+}
+
+func resetEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GITHUB_USER", "")
+	t.Setenv("NOTION_TOKEN", "")
+	t.Setenv("NOTION_DATABASE_ID", "")
+}
+
+// the github token is not required, since the command only uses notion
+var validArgs = []string{"--notion-token", "123", "--notion-database-id", "123"}
+
+var duplicatesPlan = &syncer.DedupePlan{
+	DatabaseID: "123",
+	Duplicates: []syncer.Duplicate{
+		{
+			RepoID:           431715396,
+			Name:             "nostr-rs-relay",
+			KeepPageID:       "c7d8e9f0-1a2b-4c3d-8e4f-5a6b7c8d9e0f",
+			ArchivePageIDs:   []string{"9ef240ab-18de-4808-92ee-22f6dce028e9"},
+			MergedProperties: []string{"Notes"},
+		},
+	},
+}
+
+func TestNewCommand(t *testing.T) {
+	t.Parallel()
+
+	t.Run("instanciates the command", func(t *testing.T) {
+		cmd := dedupe.NewCommand(nil)
+		require.IsType(t, &cobra.Command{}, cmd)
+		assert.Equal(t, "dedupe", cmd.Use)
+	})
+}
+
+func TestRun_WithMissingArgs_ReturnsError(t *testing.T) {
+	resetEnv(t)
+	cmd := dedupe.NewCommand(nil)
+	cmd.SetArgs([]string{"--notion-token", "123"})
+	cmd.SetOut(bytes.NewBuffer([]byte{}))
+	cmd.SetErr(bytes.NewBuffer([]byte{}))
+
+	err := cmd.Execute()
+
+	require.Error(t, err)
+	assert.Equal(t, "notion-database-id is required", err.Error())
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("merges the duplicate pages", func(t *testing.T) {
+		t.Parallel()
+
+		mockDeduper := &MockDeduper{}
+		mockDeduper.On("PlanDedupe", context.Background(), "123", syncer.KeepMostContent).Return(duplicatesPlan, nil)
+		mockDeduper.On("Dedupe", context.Background(), duplicatesPlan).Return(&syncer.SyncResult{Updated: 1, Archived: 1}, nil)
+
+		cmd := dedupe.NewCommand(func(flags sync.Flags) (dedupe.Deduper, error) {
+			return mockDeduper, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs(append(validArgs, "--keep", "most-content"))
+
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "nostr-rs-relay")
+		assert.Contains(t, out.String(), "Dedupe finished: 1 merged, 1 archived, 0 failed.")
+		mockDeduper.AssertExpectations(t)
+	})
+
+	t.Run("only prints the duplicate pages in dry run mode", func(t *testing.T) {
+		t.Parallel()
+
+		mockDeduper := &MockDeduper{}
+		mockDeduper.On("PlanDedupe", context.Background(), "123", syncer.KeepOldest).Return(duplicatesPlan, nil)
+
+		cmd := dedupe.NewCommand(func(flags sync.Flags) (dedupe.Deduper, error) {
+			return mockDeduper, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs(append(validArgs, "--dry-run"))
+
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "Found 1 repositories with duplicate pages.")
+		mockDeduper.AssertNotCalled(t, "Dedupe", mock.Anything, mock.Anything)
+	})
+
+	t.Run("returns error with an invalid keep strategy", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := dedupe.NewCommand(func(flags sync.Flags) (dedupe.Deduper, error) {
+			return &MockDeduper{}, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetErr(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs(append(validArgs, "--keep", "newest"))
+
+		err := cmd.Execute()
+
+		assert.ErrorIs(t, err, dedupe.ErrInvalidKeep)
+	})
+
+	t.Run("returns a partial failure exit code when some pages fail", func(t *testing.T) {
+		t.Parallel()
+
+		mockDeduper := &MockDeduper{}
+		mockDeduper.On("PlanDedupe", context.Background(), "123", syncer.KeepOldest).Return(duplicatesPlan, nil)
+		mockDeduper.On("Dedupe", context.Background(), duplicatesPlan).Return(&syncer.SyncResult{Updated: 1, Failed: 1}, errors.New("error archiving page"))
+
+		cmd := dedupe.NewCommand(func(flags sync.Flags) (dedupe.Deduper, error) {
+			return mockDeduper, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetErr(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs(validArgs)
+
+		err := cmd.Execute()
+
+		var exitErr *sync.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, sync.ExitCodePartialFailure, exitErr.Code)
+	})
+}
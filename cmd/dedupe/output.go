@@ -0,0 +1,64 @@
+package dedupe
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+// printPlan writes the pages kept, merged and archived for each repository with duplicate pages
+func printPlan(out io.Writer, plan *syncer.DedupePlan) error {
+	if len(plan.Duplicates) == 0 {
+		_, err := fmt.Fprintln(out, "No duplicate pages found.")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "REPOSITORY\tKEEP\tARCHIVE\tMERGED PROPERTIES"); err != nil {
+		return err
+	}
+
+	for _, duplicate := range plan.Duplicates {
+		// protected pages are left out of the merge, so they are kept along with the chosen page
+		kept := []string{duplicate.KeepPageID}
+		for _, pageID := range duplicate.ProtectedPageIDs {
+			kept = append(kept, pageID+" (protected)")
+		}
+
+		_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			duplicate.Name,
+			strings.Join(kept, ", "),
+			valueOrDash(strings.Join(duplicate.ArchivePageIDs, ", ")),
+			valueOrDash(strings.Join(duplicate.MergedProperties, ", ")),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\nFound %d repositories with duplicate pages.\n", len(plan.Duplicates))
+
+	return err
+}
+
+// printResult writes a summary of the merged and archived pages
+func printResult(out io.Writer, result *syncer.SyncResult) error {
+	_, err := fmt.Fprintf(out, "Dedupe finished: %d merged, %d archived, %d failed.\n", result.Updated, result.Archived, result.Failed)
+
+	return err
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
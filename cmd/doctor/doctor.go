@@ -0,0 +1,158 @@
+package doctor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+const (
+	FlagFix = "fix"
+	FlagYes = "yes"
+)
+
+// Doctor interface that allows you to check the setup of the sync and fix the notion database schema
+type Doctor interface {
+	Diagnose(ctx context.Context, databaseID string) *syncer.Diagnosis
+	FixSchema(ctx context.Context, databaseID string, problems []syncer.SchemaProblem) error
+}
+
+// DoctorInitializer function provides a way to initialize the doctor with the given options
+type DoctorInitializer func(flags sync.Flags) (Doctor, error)
+
+var (
+	ErrDoctorInitializerRequired = errors.New("doctor initializer is required")
+	ErrUnhealthy                 = errors.New("the sync is not ready to run, fix the failed checks above")
+)
+
+// NewCommand returns a new cobra command that checks that everything the sync depends on is correctly set up
+func NewCommand(initializerFn DoctorInitializer) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the tokens, the access to the notion database and its schema, reporting all the problems at once",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return sync.ValidateRequiredFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, initializerFn)
+		},
+	}
+
+	sync.AddSyncerFlags(command.Flags())
+	command.Flags().Bool(FlagFix, false, "Update the notion database schema to add the missing properties, and convert the ones with the wrong type after confirmation")
+	command.Flags().BoolP(FlagYes, "y", false, "Convert the properties with the wrong type without asking for confirmation")
+
+	return command
+}
+
+// run executes the doctor command
+func run(cmd *cobra.Command, initializerFn DoctorInitializer) error {
+	if initializerFn == nil {
+		return ErrDoctorInitializerRequired
+	}
+
+	ctx := cmd.Context()
+	flags, err := sync.ParseSyncerFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	fix, err := cmd.Flags().GetBool(FlagFix)
+	if err != nil {
+		return err
+	}
+
+	yes, err := cmd.Flags().GetBool(FlagYes)
+	if err != nil {
+		return err
+	}
+
+	doctorSvc, err := initializerFn(flags)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	diagnosis := doctorSvc.Diagnose(ctx, flags.NotionDatabaseID)
+	if err := printDiagnosis(out, diagnosis); err != nil {
+		return err
+	}
+
+	if fix && len(diagnosis.SchemaProblems) > 0 {
+		problems, err := selectFixes(cmd.InOrStdin(), out, diagnosis.SchemaProblems, yes)
+		if err != nil {
+			return err
+		}
+
+		if len(problems) > 0 {
+			if err := doctorSvc.FixSchema(ctx, flags.NotionDatabaseID, problems); err != nil {
+				return err
+			}
+
+			if _, err := fmt.Fprintf(out, "\nFixed %d properties of the notion database.\n\n", len(problems)); err != nil {
+				return err
+			}
+
+			// the database is checked again, to report the problems that were left unfixed
+			diagnosis = doctorSvc.Diagnose(ctx, flags.NotionDatabaseID)
+			if err := printDiagnosis(out, diagnosis); err != nil {
+				return err
+			}
+		}
+	} else if len(diagnosis.SchemaProblems) > 0 {
+		if _, err := fmt.Fprintf(out, "\nRun the doctor command with --%s to fix the schema of the notion database.\n", FlagFix); err != nil {
+			return err
+		}
+	}
+
+	if !diagnosis.Healthy() {
+		return &sync.ExitError{Code: sync.ExitCodeFailure, Err: ErrUnhealthy}
+	}
+
+	return nil
+}
+
+// selectFixes returns the schema problems that should be fixed. Missing properties are always added,
+// but converting a property may lose its values, so it requires a confirmation.
+func selectFixes(in io.Reader, out io.Writer, problems []syncer.SchemaProblem, yes bool) ([]syncer.SchemaProblem, error) {
+	reader := bufio.NewReader(in)
+	selected := make([]syncer.SchemaProblem, 0, len(problems))
+
+	for _, problem := range problems {
+		if !problem.Fixable() {
+			if _, err := fmt.Fprintf(out, "Property %s can't be fixed automatically: rename the title property of the database, or map it with --%s.\n", problem.Property, sync.FlagPropertyMap); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if problem.Missing() || yes {
+			selected = append(selected, problem)
+			continue
+		}
+
+		if _, err := fmt.Fprintf(out, "Convert property %s from %s to %s? Values that can't be converted are lost [y/N] ", problem.Property, problem.ActualType, problem.ExpectedType); err != nil {
+			return nil, err
+		}
+
+		answer, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer == "y" || answer == "yes" {
+			selected = append(selected, problem)
+		}
+	}
+
+	return selected, nil
+}
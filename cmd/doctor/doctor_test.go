@@ -0,0 +1,210 @@
+package doctor_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brpaz/github-stars-notion-sync/cmd/doctor"
+	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+type MockDoctor struct {
+	mock.Mock
+}
+
+func (m *MockDoctor) Diagnose(ctx context.Context, databaseID string) *syncer.Diagnosis {
+	args := m.Called(ctx, databaseID)
+	return args.Get(0).(*syncer.Diagnosis)
+}
+
+func (m *MockDoctor) FixSchema(ctx context.Context, databaseID string, problems []syncer.SchemaProblem) error {
+	args := m.Called(ctx, databaseID, problems)
+	return args.Error(0)
+}
+
+var (
+	missingProblem = syncer.SchemaProblem{
+		Field:        syncer.FieldTopics,
+		Property:     "Topics",
+		ExpectedType: notionapi.PropertyTypeMultiSelect,
+	}
+	wrongTypeProblem = syncer.SchemaProblem{
+		Field:        syncer.FieldLanguage,
+		Property:     "Language",
+		ExpectedType: notionapi.PropertyTypeSelect,
+		ActualType:   notionapi.PropertyTypeRichText,
+	}
+)
+
+func healthyDiagnosis() *syncer.Diagnosis {
+	return &syncer.Diagnosis{
+		Checks: []syncer.Check{
+			{Name: "notion token", Status: syncer.CheckOK, Message: "authenticated as Stars"},
+		},
+		SchemaProblems: []syncer.SchemaProblem{},
+	}
+}
+
+func unhealthyDiagnosis(problems ...syncer.SchemaProblem) *syncer.Diagnosis {
+	diagnosis := healthyDiagnosis()
+	diagnosis.Checks = append(diagnosis.Checks, syncer.Check{Name: "notion database schema", Status: syncer.CheckFailed, Message: "properties don't match"})
+	diagnosis.SchemaProblems = problems
+
+	return diagnosis
+}
+
+func resetEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GITHUB_USER", "")
+	t.Setenv("NOTION_TOKEN", "")
+	t.Setenv("NOTION_DATABASE_ID", "")
+}
+
+var validArgs = []string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"}
+
+func TestNewCommand(t *testing.T) {
+	t.Parallel()
+
+	t.Run("instanciates the command", func(t *testing.T) {
+		cmd := doctor.NewCommand(nil)
+		require.IsType(t, &cobra.Command{}, cmd)
+		assert.Equal(t, "doctor", cmd.Use)
+	})
+}
+
+func TestRun_WithMissingArgs_ReturnsError(t *testing.T) {
+	resetEnv(t)
+	cmd := doctor.NewCommand(nil)
+	cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123"})
+	cmd.SetOut(bytes.NewBuffer([]byte{}))
+	cmd.SetErr(bytes.NewBuffer([]byte{}))
+
+	err := cmd.Execute()
+
+	require.Error(t, err)
+	assert.Equal(t, "notion-database-id is required", err.Error())
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports the checks of a healthy setup", func(t *testing.T) {
+		t.Parallel()
+
+		mockDoctor := &MockDoctor{}
+		mockDoctor.On("Diagnose", context.Background(), "123").Return(healthyDiagnosis())
+
+		cmd := doctor.NewCommand(func(flags sync.Flags) (doctor.Doctor, error) {
+			return mockDoctor, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs(validArgs)
+
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "authenticated as Stars")
+		mockDoctor.AssertNotCalled(t, "FixSchema", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("reports all the schema problems without fixing them", func(t *testing.T) {
+		t.Parallel()
+
+		mockDoctor := &MockDoctor{}
+		mockDoctor.On("Diagnose", context.Background(), "123").Return(unhealthyDiagnosis(missingProblem, wrongTypeProblem))
+
+		cmd := doctor.NewCommand(func(flags sync.Flags) (doctor.Doctor, error) {
+			return mockDoctor, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetErr(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs(validArgs)
+
+		err := cmd.Execute()
+
+		var exitErr *sync.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, sync.ExitCodeFailure, exitErr.Code)
+		assert.Contains(t, out.String(), "notion database is missing required property Topics")
+		assert.Contains(t, out.String(), "notion database property Language is of type rich_text, but should be select")
+		mockDoctor.AssertNotCalled(t, "FixSchema", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("adds the missing properties and asks before converting the others", func(t *testing.T) {
+		t.Parallel()
+
+		mockDoctor := &MockDoctor{}
+		mockDoctor.On("Diagnose", context.Background(), "123").Return(unhealthyDiagnosis(missingProblem, wrongTypeProblem)).Once()
+		mockDoctor.On("FixSchema", context.Background(), "123", []syncer.SchemaProblem{missingProblem}).Return(nil)
+		mockDoctor.On("Diagnose", context.Background(), "123").Return(unhealthyDiagnosis(wrongTypeProblem)).Once()
+
+		cmd := doctor.NewCommand(func(flags sync.Flags) (doctor.Doctor, error) {
+			return mockDoctor, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetErr(bytes.NewBuffer([]byte{}))
+		cmd.SetIn(strings.NewReader("n\n"))
+		cmd.SetArgs(append(validArgs, "--fix"))
+
+		err := cmd.Execute()
+
+		require.Error(t, err)
+		assert.Contains(t, out.String(), "Convert property Language from rich_text to select?")
+		mockDoctor.AssertExpectations(t)
+	})
+
+	t.Run("converts the properties without asking with --yes", func(t *testing.T) {
+		t.Parallel()
+
+		mockDoctor := &MockDoctor{}
+		mockDoctor.On("Diagnose", context.Background(), "123").Return(unhealthyDiagnosis(missingProblem, wrongTypeProblem)).Once()
+		mockDoctor.On("FixSchema", context.Background(), "123", []syncer.SchemaProblem{missingProblem, wrongTypeProblem}).Return(nil)
+		mockDoctor.On("Diagnose", context.Background(), "123").Return(healthyDiagnosis()).Once()
+
+		cmd := doctor.NewCommand(func(flags sync.Flags) (doctor.Doctor, error) {
+			return mockDoctor, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs(append(validArgs, "--fix", "--yes"))
+
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Contains(t, out.String(), "Fixed 2 properties")
+		mockDoctor.AssertExpectations(t)
+	})
+
+	t.Run("returns the error of the schema fix", func(t *testing.T) {
+		t.Parallel()
+
+		mockDoctor := &MockDoctor{}
+		mockDoctor.On("Diagnose", context.Background(), "123").Return(unhealthyDiagnosis(missingProblem))
+		mockDoctor.On("FixSchema", context.Background(), "123", []syncer.SchemaProblem{missingProblem}).Return(errors.New("forbidden"))
+
+		cmd := doctor.NewCommand(func(flags sync.Flags) (doctor.Doctor, error) {
+			return mockDoctor, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetErr(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs(append(validArgs, "--fix"))
+
+		err := cmd.Execute()
+
+		require.EqualError(t, err, "forbidden")
+	})
+}
@@ -0,0 +1,43 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+// printDiagnosis writes the result of each check, followed by the schema problems of the notion database
+func printDiagnosis(out io.Writer, diagnosis *syncer.Diagnosis) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(w, "STATUS\tCHECK\tMESSAGE"); err != nil {
+		return err
+	}
+
+	for _, check := range diagnosis.Checks {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%s\n", check.Status, check.Name, check.Message); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(diagnosis.SchemaProblems) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintln(out, "\nSchema problems:"); err != nil {
+		return err
+	}
+
+	for _, problem := range diagnosis.SchemaProblems {
+		if _, err := fmt.Fprintf(out, "  - %s\n", problem); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
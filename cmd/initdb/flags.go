@@ -0,0 +1,123 @@
+package initdb
+
+import (
+	"errors"
+
+	"github.com/spf13/pflag"
+
+	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+const (
+	FlagNotionToken     = "notion-token"
+	FlagParentPageID    = "parent-page-id"
+	FlagTitle           = "title"
+	FlagSyncLists       = "sync-lists"
+	FlagListsDatabaseID = "lists-database-id"
+	FlagStarredBy       = "starred-by"
+	FlagOnUnstar        = "on-unstar"
+)
+
+var (
+	ErrNotionTokenRequired  = errors.New("notion-token is required")
+	ErrParentPageIDRequired = errors.New("parent-page-id is required")
+)
+
+// Flags encapsulates all the options that are required to run the init command
+type Flags struct {
+	NotionToken string
+	// ParentPageID is the id of the notion page where the database is created
+	ParentPageID string
+	Title        string
+	// PropertyMapping maps the synced fields (ex: "title", "topics") to the names of the created properties
+	PropertyMapping map[string]string
+	// SyncLists adds the property that stores the star lists
+	SyncLists bool
+	// ListsDatabaseID is the id of the notion database that stores the star lists, when they are synced to a relation
+	ListsDatabaseID string
+	// StarredBy adds the property that stores the accounts that starred each repo
+	StarredBy bool
+	// OnUnstar adds the properties required by the policy applied to the pages of unstarred repos
+	OnUnstar string
+}
+
+// a map of required flags and their respective error.
+var requiredFlags = map[string]error{
+	FlagNotionToken:  ErrNotionTokenRequired,
+	FlagParentPageID: ErrParentPageIDRequired,
+}
+
+// validateRequiredFlags validates the flags passed to the init command
+func validateRequiredFlags(flags *pflag.FlagSet) error {
+	for flagName, flagErr := range requiredFlags {
+		flagValue, err := flags.GetString(flagName)
+		if err != nil {
+			return err
+		}
+
+		if flagValue == "" {
+			return flagErr
+		}
+	}
+
+	return nil
+}
+
+// parseFlags parses the flags received in the command and construct a "Flags" struct with their values
+func parseFlags(flags *pflag.FlagSet) (Flags, error) {
+	notionToken, err := flags.GetString(FlagNotionToken)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	parentPageID, err := flags.GetString(FlagParentPageID)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	title, err := flags.GetString(FlagTitle)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	propertyMapping, err := sync.ParsePropertyMapping(flags)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	syncLists, err := flags.GetBool(FlagSyncLists)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	listsDatabaseID, err := flags.GetString(FlagListsDatabaseID)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	starredBy, err := flags.GetBool(FlagStarredBy)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	onUnstar, err := flags.GetString(FlagOnUnstar)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	if syncer.UnstarPolicy(onUnstar).Validate() != nil {
+		return Flags{}, sync.ErrInvalidOnUnstar
+	}
+
+	return Flags{
+		NotionToken:     notionToken,
+		ParentPageID:    parentPageID,
+		Title:           title,
+		PropertyMapping: propertyMapping,
+		SyncLists:       syncLists,
+		ListsDatabaseID: listsDatabaseID,
+		StarredBy:       starredBy,
+		OnUnstar:        onUnstar,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package initdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+// DatabaseCreator creates a notion database with the properties required to sync the stars, and returns its id
+type DatabaseCreator func(ctx context.Context, flags Flags) (string, error)
+
+var ErrDatabaseCreatorRequired = errors.New("database creator is required")
+
+// NewCommand returns a new cobra command that creates a notion database ready to be synced
+func NewCommand(creatorFn DatabaseCreator) *cobra.Command {
+	command := &cobra.Command{
+		Use:   "init",
+		Short: "Create a notion database with the properties required to sync your github stars",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateRequiredFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, creatorFn)
+		},
+	}
+
+	command.Flags().String(FlagNotionToken, os.Getenv("NOTION_TOKEN"), "A notion token to authenticate with the notion api")
+	command.Flags().String(FlagParentPageID, os.Getenv("NOTION_PARENT_PAGE_ID"), "The id of the notion page where the database is created. The integration must have access to it")
+	command.Flags().String(FlagTitle, syncer.DefaultDatabaseTitle, "The title of the database")
+	command.Flags().StringToString(sync.FlagPropertyMap, map[string]string{}, "Custom names for the notion database properties (ex: title=Repo,description=Summary,topics=Tags)")
+	command.Flags().String(sync.FlagPropertyMapFile, os.Getenv("NOTION_PROPERTY_MAP_FILE"), "Path to a JSON file with custom names for the notion database properties")
+	command.Flags().Bool(FlagSyncLists, false, "Add the property that stores the github star lists of each repository")
+	command.Flags().String(FlagListsDatabaseID, os.Getenv("NOTION_LISTS_DATABASE_ID"), "The id of a notion database that stores the star lists. When set, the lists property is a relation to it")
+	command.Flags().Bool(FlagStarredBy, false, "Add the property that stores the accounts that starred each repository, used to sync several accounts")
+	command.Flags().String(FlagOnUnstar, string(syncer.UnstarArchive), "The policy applied to the pages of unstarred repositories by the sync command. With mark, the properties that mark them are added")
+
+	return command
+}
+
+// run executes the init command
+func run(cmd *cobra.Command, creatorFn DatabaseCreator) error {
+	if creatorFn == nil {
+		return ErrDatabaseCreatorRequired
+	}
+
+	flags, err := parseFlags(cmd.Flags())
+	if err != nil {
+		return err
+	}
+
+	databaseID, err := creatorFn(cmd.Context(), flags)
+	if err != nil {
+		return err
+	}
+
+	// the id is printed alone, so that it can be captured by scripts and used with --notion-database-id
+	_, err = fmt.Fprintln(cmd.OutOrStdout(), databaseID)
+
+	return err
+}
@@ -0,0 +1,112 @@
+package initdb_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brpaz/github-stars-notion-sync/cmd/initdb"
+)
+
+func resetEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("NOTION_TOKEN", "")
+	t.Setenv("NOTION_PARENT_PAGE_ID", "")
+	t.Setenv("NOTION_PROPERTY_MAP_FILE", "")
+	t.Setenv("NOTION_LISTS_DATABASE_ID", "")
+}
+
+func TestNewCommand(t *testing.T) {
+	t.Parallel()
+
+	t.Run("instanciates the command", func(t *testing.T) {
+		cmd := initdb.NewCommand(nil)
+		require.IsType(t, &cobra.Command{}, cmd)
+		assert.Equal(t, "init", cmd.Use)
+	})
+}
+
+func TestRun_WithMissingArgs_ReturnsError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "should return error if notion token is not provided",
+			args:     []string{"--parent-page-id", "123"},
+			expected: "notion-token is required",
+		},
+		{
+			name:     "should return error if parent page id is not provided",
+			args:     []string{"--notion-token", "123"},
+			expected: "parent-page-id is required",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resetEnv(t)
+			cmd := initdb.NewCommand(nil)
+			cmd.SetArgs(tc.args)
+			cmd.SetOut(bytes.NewBuffer([]byte{}))
+			cmd.SetErr(bytes.NewBuffer([]byte{}))
+
+			err := cmd.Execute()
+
+			require.Error(t, err)
+			require.Equal(t, tc.expected, err.Error())
+		})
+	}
+}
+
+func TestRun_WithValidArgs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("prints the id of the created database", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags initdb.Flags
+		cmd := initdb.NewCommand(func(ctx context.Context, flags initdb.Flags) (string, error) {
+			receivedFlags = flags
+			return "705baa92-0ea9-4a4f-bb97-4916d1cb45bc", nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--notion-token", "123", "--parent-page-id", "456", "--title", "Stars", "--property-map", "title=Repo", "--sync-lists", "--starred-by", "--on-unstar", "mark"})
+
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, "705baa92-0ea9-4a4f-bb97-4916d1cb45bc\n", out.String())
+		assert.Equal(t, initdb.Flags{
+			NotionToken:     "123",
+			ParentPageID:    "456",
+			Title:           "Stars",
+			PropertyMapping: map[string]string{"title": "Repo"},
+			SyncLists:       true,
+			StarredBy:       true,
+			OnUnstar:        "mark",
+		}, receivedFlags)
+	})
+
+	t.Run("returns the error of the database creation", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := initdb.NewCommand(func(ctx context.Context, flags initdb.Flags) (string, error) {
+			return "", errors.New("error creating notion database")
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetErr(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--notion-token", "123", "--parent-page-id", "456"})
+
+		err := cmd.Execute()
+
+		assert.EqualError(t, err, "error creating notion database")
+	})
+}
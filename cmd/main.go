@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"os"
 	"runtime"
 
+	"github.com/brpaz/github-stars-notion-sync/cmd/dedupe"
+	"github.com/brpaz/github-stars-notion-sync/cmd/doctor"
+	"github.com/brpaz/github-stars-notion-sync/cmd/initdb"
 	"github.com/brpaz/github-stars-notion-sync/cmd/root"
 	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
 	versionCmd "github.com/brpaz/github-stars-notion-sync/cmd/version"
+	"github.com/brpaz/github-stars-notion-sync/internal/etag"
+	"github.com/brpaz/github-stars-notion-sync/internal/retry"
+	"github.com/brpaz/github-stars-notion-sync/internal/state"
 	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
 	"github.com/google/go-github/v57/github"
 	"github.com/jomei/notionapi"
@@ -25,24 +34,166 @@ func main() {
 	registerCommands(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *sync.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		os.Exit(1)
 	}
 }
 
 func initSyncer(flags sync.Flags) (sync.Syncer, error) {
-	gitHubClient := github.
-		NewClient(nil).
-		WithAuthToken(flags.GitHubToken)
+	syncerSvc, err := newSyncer(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return syncerSvc, nil
+}
+
+func initDeduper(flags sync.Flags) (dedupe.Deduper, error) {
+	syncerSvc, err := newSyncer(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return syncerSvc, nil
+}
+
+func initDoctor(flags sync.Flags) (doctor.Doctor, error) {
+	syncerSvc, err := newSyncer(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return syncerSvc, nil
+}
+
+// newSyncer creates a syncer configured with the given flags
+func newSyncer(flags sync.Flags) (*syncer.Syncer, error) {
+	// both api clients use a transport that retries the requests that failed with transient errors
+	retryConfig := retry.DefaultConfig()
+	retryConfig.MaxRetries = flags.MaxRetries
+	retryConfig.MaxWait = flags.RetryMaxWait
+	retryTransport := retry.NewTransport(http.DefaultTransport, retryConfig)
+
+	// the retries of the notion requests wait for the same limiter as the requests made by the syncer
+	notionLimiter := syncer.NewNotionLimiter(flags.NotionRPS)
+	notionTransport := retry.NewTransport(http.DefaultTransport, retryConfig)
+	notionTransport.Limiter = notionLimiter
+	notionTransport.ReadOnly = syncer.IsNotionReadOnly
+
+	propertyMapping := make(syncer.PropertyMapping, len(flags.PropertyMapping))
+	for field, propertyName := range flags.PropertyMapping {
+		propertyMapping[syncer.Field(field)] = propertyName
+	}
+
+	opts := []syncer.Option{
+		syncer.WithPropertyMapping(propertyMapping),
+		syncer.WithConcurrency(flags.Concurrency),
+		syncer.WithNotionRateLimit(flags.NotionRPS),
+		syncer.WithNotionLimiter(notionLimiter),
+		syncer.WithFullSyncInterval(flags.FullSyncInterval),
+	}
+
+	if flags.GitHubUser != "" {
+		opts = append(opts, syncer.WithGitHubUser(flags.GitHubUser))
+	}
+
+	if flags.ListsDatabaseID != "" {
+		opts = append(opts, syncer.WithListsDatabase(flags.ListsDatabaseID))
+	} else if flags.SyncLists {
+		opts = append(opts, syncer.WithStarLists())
+	}
+
+	if flags.SyncReadme {
+		opts = append(opts, syncer.WithReadme())
+	}
+
+	if flags.OnUnstar != "" {
+		opts = append(opts, syncer.WithUnstarPolicy(syncer.UnstarPolicy(flags.OnUnstar)))
+	}
+
+	if flags.ArchiveDatabaseID != "" {
+		opts = append(opts, syncer.WithArchiveDatabase(flags.ArchiveDatabaseID))
+	}
+
+	if flags.MaxDelete != nil && !flags.Force {
+		opts = append(opts, syncer.WithDeleteLimit(*flags.MaxDelete))
+	}
+
+	var gitHubTransport http.RoundTripper = retryTransport
+
+	// the state store stays open until the application exits
+	if flags.StateFile != "" {
+		store, err := state.Open(flags.StateFile)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, syncer.WithStateStore(store))
+
+		// the requests that list the starred repos are made conditional on the responses cached in the state store,
+		// so that unchanged responses don't count against the rate limit
+		etagTransport := etag.NewTransport(retryTransport, store)
+		etagTransport.Match = syncer.IsGitHubStarsRequest
+		gitHubTransport = etagTransport
+	}
+
+	gitHubClient := newGitHubClient(gitHubTransport, flags.GitHubToken)
+	for _, account := range flags.GitHubAccounts {
+		opts = append(opts, syncer.WithGitHubAccount(newGitHubClient(gitHubTransport, account.Token), account.User))
+	}
 
 	notionClient := notionapi.NewClient(
 		notionapi.Token(flags.NotionToken),
+		notionapi.WithHTTPClient(&http.Client{Transport: notionTransport}),
 	)
 
-	return syncer.New(gitHubClient, notionClient)
+	return syncer.New(gitHubClient, notionClient, opts...)
+}
+
+func createDatabase(ctx context.Context, flags initdb.Flags) (string, error) {
+	notionClient := notionapi.NewClient(
+		notionapi.Token(flags.NotionToken),
+		notionapi.WithHTTPClient(&http.Client{
+			Transport: retry.NewTransport(http.DefaultTransport, retry.DefaultConfig()),
+		}),
+	)
+
+	propertyMapping := make(syncer.PropertyMapping, len(flags.PropertyMapping))
+	for field, propertyName := range flags.PropertyMapping {
+		propertyMapping[syncer.Field(field)] = propertyName
+	}
+
+	return syncer.CreateDatabase(ctx, notionClient, syncer.DatabaseOptions{
+		ParentPageID:    flags.ParentPageID,
+		Title:           flags.Title,
+		Mapping:         propertyMapping,
+		StarLists:       flags.SyncLists,
+		ListsDatabaseID: flags.ListsDatabaseID,
+		StarredBy:       flags.StarredBy,
+		UnstarPolicy:    syncer.UnstarPolicy(flags.OnUnstar),
+	})
+}
+
+// newGitHubClient creates a github client authenticated with the given token.
+// Without a token, only public data can be fetched, with a lower rate limit.
+func newGitHubClient(transport http.RoundTripper, token string) *github.Client {
+	client := github.NewClient(&http.Client{Transport: transport})
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+
+	return client
 }
 
 func registerCommands(rootCmd *cobra.Command) {
 	rootCmd.AddCommand(sync.NewCommand(initSyncer))
+	rootCmd.AddCommand(initdb.NewCommand(createDatabase))
+	rootCmd.AddCommand(doctor.NewCommand(initDoctor))
+	rootCmd.AddCommand(dedupe.NewCommand(initDeduper))
 	rootCmd.AddCommand(versionCmd.NewCommand(versionCmd.VersionInfo{
 		Version:   version,
 		GitCommit: gitCommit,
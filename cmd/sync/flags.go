@@ -1,28 +1,111 @@
 package sync
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/retry"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
 )
 
 const (
-	FlagGitHubToken      = "github-token"
-	FlagNotionToken      = "notion-token"
-	FlagNotionDatabaseID = "notion-database-id"
+	FlagGitHubToken       = "github-token"
+	FlagGitHubUser        = "github-user"
+	FlagGitHubAccount     = "github-account"
+	FlagNotionToken       = "notion-token"
+	FlagNotionDatabaseID  = "notion-database-id"
+	FlagPropertyMap       = "property-map"
+	FlagPropertyMapFile   = "property-map-file"
+	FlagDryRun            = "dry-run"
+	FlagOutput            = "output"
+	FlagConcurrency       = "concurrency"
+	FlagNotionRPS         = "notion-rps"
+	FlagMaxRetries        = "max-retries"
+	FlagRetryMaxWait      = "retry-max-wait"
+	FlagStateFile         = "state-file"
+	FlagFullEvery         = "full-every"
+	FlagSyncLists         = "sync-lists"
+	FlagListsDatabaseID   = "lists-database-id"
+	FlagSyncReadme        = "sync-readme"
+	FlagOnUnstar          = "on-unstar"
+	FlagArchiveDatabaseID = "archive-database-id"
+	FlagMaxDelete         = "max-delete"
+	FlagForce             = "force"
 )
 
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// gitHubAccountTokenPrefix identifies the entries of the github-account flag that hold a token instead of a username
+const gitHubAccountTokenPrefix = "token:"
+
 var (
 	ErrGitHubTokenRequired      = errors.New("github-token is required")
 	ErrNotionTokenRequired      = errors.New("notion-token is required")
 	ErrNotionDatabaseIDRequired = errors.New("notion-database-id is required")
+	ErrInvalidOutput            = errors.New("output must be one of: table, json")
+	ErrListsRequireGitHubToken  = errors.New("github-token is required to sync star lists")
+	ErrEmptyGitHubAccount       = errors.New("github-account must be a username or a token prefixed with \"token:\"")
+	ErrInvalidOnUnstar          = errors.New("on-unstar must be one of: archive, keep, mark, move")
+	ErrArchiveDatabaseRequired  = errors.New("archive-database-id is required when on-unstar is move")
+	ErrInvalidMaxDelete         = errors.New("max-delete must be a number of pages or a percentage, like 10 or 5%")
 )
 
 // Flags encapsulates all the options that are required to run the sync command
 type Flags struct {
-	GitHubToken      string
+	GitHubToken string
+	// GitHubUser is the user whose stars are synced. When empty, the stars of the token owner are synced.
+	GitHubUser string
+	// GitHubAccounts holds the additional github accounts whose stars are synced to the same notion database
+	GitHubAccounts   []GitHubAccount
 	NotionToken      string
 	NotionDatabaseID string
+	// PropertyMapping maps the synced fields (ex: "title", "topics") to the names of the notion database properties
+	PropertyMapping map[string]string
+	// DryRun computes the changes of the sync without applying them to the notion database
+	DryRun bool
+	// Output is the format used to print the plan in dry run mode
+	Output string
+	// Concurrency is the number of notion pages that are synced in parallel
+	Concurrency int
+	// NotionRPS is the average number of requests per second made to the notion api
+	NotionRPS float64
+	// MaxRetries is the maximum number of times a request that failed with a transient error is retried
+	MaxRetries int
+	// RetryMaxWait is the maximum time to wait before retrying a failed request
+	RetryMaxWait time.Duration
+	// StateFile is the path of the local file that persists information between syncs
+	StateFile string
+	// FullSyncInterval is how often a full sync runs, when a state file is used
+	FullSyncInterval time.Duration
+	// SyncLists enables the sync of the github star lists of each repo
+	SyncLists bool
+	// ListsDatabaseID is the id of the notion database that stores the star lists, when they are synced to a relation
+	ListsDatabaseID string
+	// SyncReadme renders the README of each repo as the body of its created page
+	SyncReadme bool
+	// OnUnstar is what a full sync does to the pages of unstarred repos: archive, keep, mark or move
+	OnUnstar string
+	// ArchiveDatabaseID is the id of the notion database where the pages of unstarred repos are moved
+	ArchiveDatabaseID string
+	// MaxDelete is the maximum number of pages that the sync can remove. When nil, there is no limit.
+	MaxDelete *syncer.DeleteLimit
+	// Force applies the sync even when it removes more pages than MaxDelete allows
+	Force bool
+}
+
+// GitHubAccount identifies a github account whose stars are synced, either by its username or by a token
+type GitHubAccount struct {
+	User  string
+	Token string
 }
 
 // a map of required flags and their respective error.
@@ -32,9 +115,19 @@ var requiredFlags = map[string]error{
 	FlagNotionDatabaseID: ErrNotionDatabaseIDRequired,
 }
 
-// validateRequiredFlags validates the flags passed to the sync command
-func validateRequiredFlags(flags *pflag.FlagSet) error {
+// ValidateRequiredFlags validates the flags registered by AddSyncerFlags
+func ValidateRequiredFlags(flags *pflag.FlagSet) error {
+	gitHubUser, err := flags.GetString(FlagGitHubUser)
+	if err != nil {
+		return err
+	}
+
 	for flagName, flagErr := range requiredFlags {
+		// the stars of a given user are public, so they can be fetched without a token
+		if flagName == FlagGitHubToken && gitHubUser != "" {
+			continue
+		}
+
 		flagValue, err := flags.GetString(flagName)
 		if err != nil {
 			return err
@@ -44,16 +137,90 @@ func validateRequiredFlags(flags *pflag.FlagSet) error {
 			return flagErr
 		}
 	}
+
+	gitHubToken, err := flags.GetString(FlagGitHubToken)
+	if err != nil {
+		return err
+	}
+
+	syncLists, err := flags.GetBool(FlagSyncLists)
+	if err != nil {
+		return err
+	}
+
+	listsDatabaseID, err := flags.GetString(FlagListsDatabaseID)
+	if err != nil {
+		return err
+	}
+
+	// star lists are only available in the github graphql api, which requires authentication
+	if gitHubToken == "" && (syncLists || listsDatabaseID != "") {
+		return ErrListsRequireGitHubToken
+	}
+
 	return nil
 }
 
 // parseFlags parses the flags received in the command and construct an "Options" struct with their values
 func parseFlags(flags *pflag.FlagSet) (Flags, error) {
+	result, err := ParseSyncerFlags(flags)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	result.DryRun, err = flags.GetBool(FlagDryRun)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	result.Output, err = flags.GetString(FlagOutput)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	if result.Output != OutputTable && result.Output != OutputJSON {
+		return Flags{}, ErrInvalidOutput
+	}
+
+	maxDelete, err := flags.GetString(FlagMaxDelete)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	if maxDelete != "" {
+		limit, err := syncer.ParseDeleteLimit(maxDelete)
+		if err != nil {
+			return Flags{}, ErrInvalidMaxDelete
+		}
+
+		result.MaxDelete = &limit
+	}
+
+	result.Force, err = flags.GetBool(FlagForce)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	return result, nil
+}
+
+// ParseSyncerFlags parses the flags registered by AddSyncerFlags
+func ParseSyncerFlags(flags *pflag.FlagSet) (Flags, error) {
 	gitHubToken, err := flags.GetString(FlagGitHubToken)
 	if err != nil {
 		return Flags{}, err
 	}
 
+	gitHubUser, err := flags.GetString(FlagGitHubUser)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	gitHubAccounts, err := parseGitHubAccounts(flags)
+	if err != nil {
+		return Flags{}, err
+	}
+
 	notionToken, _ := flags.GetString(FlagNotionToken)
 
 	if err != nil {
@@ -66,9 +233,195 @@ func parseFlags(flags *pflag.FlagSet) (Flags, error) {
 		return Flags{}, err
 	}
 
+	propertyMapping, err := ParsePropertyMapping(flags)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	concurrency, err := flags.GetInt(FlagConcurrency)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	notionRPS, err := flags.GetFloat64(FlagNotionRPS)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	maxRetries, err := flags.GetInt(FlagMaxRetries)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	retryMaxWait, err := flags.GetDuration(FlagRetryMaxWait)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	stateFile, err := flags.GetString(FlagStateFile)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	fullSyncInterval, err := flags.GetDuration(FlagFullEvery)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	syncLists, err := flags.GetBool(FlagSyncLists)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	listsDatabaseID, err := flags.GetString(FlagListsDatabaseID)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	syncReadme, err := flags.GetBool(FlagSyncReadme)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	onUnstar, err := flags.GetString(FlagOnUnstar)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	if syncer.UnstarPolicy(onUnstar).Validate() != nil {
+		return Flags{}, ErrInvalidOnUnstar
+	}
+
+	archiveDatabaseID, err := flags.GetString(FlagArchiveDatabaseID)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	if syncer.UnstarPolicy(onUnstar) == syncer.UnstarMove && archiveDatabaseID == "" {
+		return Flags{}, ErrArchiveDatabaseRequired
+	}
+
 	return Flags{
-		GitHubToken:      gitHubToken,
-		NotionToken:      notionToken,
-		NotionDatabaseID: notionDatabaseID,
+		GitHubToken:       gitHubToken,
+		GitHubUser:        gitHubUser,
+		GitHubAccounts:    gitHubAccounts,
+		NotionToken:       notionToken,
+		NotionDatabaseID:  notionDatabaseID,
+		PropertyMapping:   propertyMapping,
+		Concurrency:       concurrency,
+		NotionRPS:         notionRPS,
+		MaxRetries:        maxRetries,
+		RetryMaxWait:      retryMaxWait,
+		StateFile:         stateFile,
+		FullSyncInterval:  fullSyncInterval,
+		SyncLists:         syncLists,
+		ListsDatabaseID:   listsDatabaseID,
+		SyncReadme:        syncReadme,
+		OnUnstar:          onUnstar,
+		ArchiveDatabaseID: archiveDatabaseID,
 	}, nil
 }
+
+// ParsePropertyMapping builds the property mapping from the mapping file and the mapping flag.
+// Entries of the flag take precedence over the ones defined in the file.
+// It is shared by the commands that accept custom property names.
+func ParsePropertyMapping(flags *pflag.FlagSet) (map[string]string, error) {
+	propertyMapping := make(map[string]string)
+
+	mappingFile, err := flags.GetString(FlagPropertyMapFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if mappingFile != "" {
+		data, err := os.ReadFile(mappingFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading property mapping file: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &propertyMapping); err != nil {
+			return nil, fmt.Errorf("error parsing property mapping file: %w", err)
+		}
+	}
+
+	mappingFlag, err := flags.GetStringToString(FlagPropertyMap)
+	if err != nil {
+		return nil, err
+	}
+
+	for field, propertyName := range mappingFlag {
+		propertyMapping[field] = propertyName
+	}
+
+	return propertyMapping, nil
+}
+
+// parseGitHubAccounts parses the additional github accounts. Each entry is either a username, whose public stars are
+// fetched without authentication, or a token prefixed with "token:", whose owner stars are fetched.
+func parseGitHubAccounts(flags *pflag.FlagSet) ([]GitHubAccount, error) {
+	entries, err := flags.GetStringSlice(FlagGitHubAccount)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]GitHubAccount, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		account := GitHubAccount{User: entry}
+		if token, ok := strings.CutPrefix(entry, gitHubAccountTokenPrefix); ok {
+			account = GitHubAccount{Token: token}
+		}
+
+		if account.User == "" && account.Token == "" {
+			return nil, ErrEmptyGitHubAccount
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+// AddSyncerFlags registers the flags that configure the syncer in the given flag set.
+// It is shared by the commands that connect to github and notion like the sync command does.
+func AddSyncerFlags(flags *pflag.FlagSet) {
+	flags.StringP(FlagGitHubToken, "", os.Getenv("GITHUB_TOKEN"), "A github token to authenticate with the github api")
+	flags.String(FlagGitHubUser, os.Getenv("GITHUB_USER"), "The github user whose stars are synced. Defaults to the owner of the github token, which is not required for public stars")
+	flags.StringSlice(FlagGitHubAccount, envStringSlice("GITHUB_ACCOUNTS"), "Additional github accounts whose stars are synced to the same notion database. Each one is a username or a token prefixed with \"token:\"")
+	flags.StringP(FlagNotionToken, "", os.Getenv("NOTION_TOKEN"), "A notion token to authenticate with the notion api")
+	flags.StringP(FlagNotionDatabaseID, "", os.Getenv("NOTION_DATABASE_ID"), "The id of the notion database to sync with")
+	flags.StringToString(FlagPropertyMap, map[string]string{}, "Custom names for the notion database properties (ex: title=Repo,description=Summary,topics=Tags)")
+	flags.String(FlagPropertyMapFile, os.Getenv("NOTION_PROPERTY_MAP_FILE"), "Path to a JSON file with custom names for the notion database properties")
+	flags.Int(FlagConcurrency, syncer.DefaultConcurrency, "The number of notion pages to sync in parallel")
+	flags.String(FlagStateFile, os.Getenv("SYNC_STATE_FILE"), "Path to a local file that keeps the state between syncs. Enables incremental syncs")
+	flags.Duration(FlagFullEvery, syncer.DefaultFullSyncInterval, "How often to run a full sync, that also archives the pages of unstarred repos, when a state file is used (0 to always run full syncs)")
+	flags.Int(FlagMaxRetries, retry.DefaultMaxRetries, "The maximum number of times a request that failed with a transient error is retried (0 to disable retries)")
+	flags.Duration(FlagRetryMaxWait, retry.DefaultMaxWait, "The maximum time to wait before retrying a failed request")
+	flags.Bool(FlagSyncLists, false, "Sync the github star lists of each repository to the \"lists\" property of the notion database")
+	flags.String(FlagListsDatabaseID, os.Getenv("NOTION_LISTS_DATABASE_ID"), "The id of a notion database that stores the star lists. When set, the lists are synced to a relation property")
+	flags.Bool(FlagSyncReadme, false, "Render the README of each repository as the body of its notion page, when the page is created")
+	flags.String(FlagOnUnstar, string(syncer.UnstarArchive), "What a full sync does to the pages of unstarred repositories: archive, keep, mark or move. Pages with a checked \"Protected\" property are never touched")
+	flags.String(FlagArchiveDatabaseID, os.Getenv("NOTION_ARCHIVE_DATABASE_ID"), "The id of the notion database where the pages of unstarred repositories are moved, when on-unstar is move")
+	flags.Float64(FlagNotionRPS, syncer.DefaultNotionRPS, "The average number of requests per second made to the notion api (0 to disable the limit)")
+
+}
+
+// envOrDefault returns the value of the given environment variable, or the fallback when it's not set
+func envOrDefault(name string, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+
+	return fallback
+}
+
+// envStringSlice returns the comma separated values of the given environment variable
+func envStringSlice(name string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return []string{}
+	}
+
+	return strings.Split(value, ",")
+}
@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
+)
+
+// printPlan writes the changes of the plan to the given writer, using the specified output format
+func printPlan(out io.Writer, plan *syncer.Plan, format string) error {
+	if format == OutputJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(plan)
+	}
+
+	return printPlanTable(out, plan)
+}
+
+// printPlanTable writes the changes of the plan as a human readable table
+func printPlanTable(out io.Writer, plan *syncer.Plan) error {
+	if len(plan.Changes) > 0 {
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		if _, err := fmt.Fprintln(w, "ACTION\tREPOSITORY\tPAGE\tCHANGES"); err != nil {
+			return err
+		}
+
+		for _, change := range plan.Changes {
+			if _, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Action, change.Name, valueOrDash(change.PageID), valueOrDash(strings.Join(change.Properties, ", "))); err != nil {
+				return err
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintln(out); err != nil {
+			return err
+		}
+	}
+
+	summary := fmt.Sprintf("%d to create, %d to update, %d to archive",
+		plan.Count(syncer.ActionCreate),
+		plan.Count(syncer.ActionUpdate),
+		plan.Count(syncer.ActionArchive),
+	)
+
+	if count := plan.Count(syncer.ActionRestore); count > 0 {
+		summary += fmt.Sprintf(", %d to restore", count)
+	}
+
+	if count := plan.Count(syncer.ActionAdopt); count > 0 {
+		summary += fmt.Sprintf(", %d to adopt", count)
+	}
+
+	// the actions of the other unstar policies are only shown when they are used
+	if count := plan.Count(syncer.ActionMark); count > 0 {
+		summary += fmt.Sprintf(", %d to mark", count)
+	}
+
+	if count := plan.Count(syncer.ActionMove); count > 0 {
+		summary += fmt.Sprintf(", %d to move", count)
+	}
+
+	if _, err := fmt.Fprintf(out, "Plan: %s, %d unchanged.\n", summary, plan.Skipped); err != nil {
+		return err
+	}
+
+	if err := printMalformedWarning(out, plan.Malformed); err != nil {
+		return err
+	}
+
+	return printDuplicatesWarning(out, len(plan.Duplicates))
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+// printResult writes a summary of the changes applied by the sync
+func printResult(out io.Writer, result *syncer.SyncResult) error {
+	summary := fmt.Sprintf("%d created, %d updated, %d archived", result.Created, result.Updated, result.Archived)
+
+	if result.Restored > 0 {
+		summary += fmt.Sprintf(", %d restored", result.Restored)
+	}
+
+	if result.Adopted > 0 {
+		summary += fmt.Sprintf(", %d adopted", result.Adopted)
+	}
+
+	if result.Marked > 0 {
+		summary += fmt.Sprintf(", %d marked", result.Marked)
+	}
+
+	if result.Moved > 0 {
+		summary += fmt.Sprintf(", %d moved", result.Moved)
+	}
+
+	if _, err := fmt.Fprintf(out, "Sync finished: %s, %d skipped, %d failed.\n", summary, result.Skipped, result.Failed); err != nil {
+		return err
+	}
+
+	if err := printMalformedWarning(out, result.Malformed); err != nil {
+		return err
+	}
+
+	return printDuplicatesWarning(out, result.Duplicates)
+}
+
+// printMalformedWarning lists the pages of the notion database that were skipped because they can't be matched with
+// a repo, so that they can be fixed by hand
+func printMalformedWarning(out io.Writer, malformed []syncer.MalformedPage) error {
+	if len(malformed) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(out, "Warning: %d pages can't be matched with a repository and were skipped:\n", len(malformed)); err != nil {
+		return err
+	}
+
+	for _, page := range malformed {
+		if _, err := fmt.Fprintf(out, "  - %s: %s\n", valueOrDash(page.PageURL), page.Reason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// printDuplicatesWarning warns about the repos that have several pages in the notion database
+func printDuplicatesWarning(out io.Writer, duplicates int) error {
+	if duplicates == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(out, "Warning: %d repositories have duplicate pages. Run the dedupe command to merge them.\n", duplicates)
+
+	return err
+}
@@ -3,14 +3,17 @@ package sync
 import (
 	"context"
 	"errors"
-	"os"
+	"fmt"
 
 	"github.com/spf13/cobra"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
 )
 
 // Syncer interface that allows you to sync your github stars with a notion database
 type Syncer interface {
-	SyncStars(ctx context.Context, databaseID string) error
+	SyncStars(ctx context.Context, databaseID string) (*syncer.SyncResult, error)
+	Plan(ctx context.Context, databaseID string) (*syncer.Plan, error)
 }
 
 // SyncerInitializer function provides a way to initialize the syncer with the given options
@@ -19,22 +22,44 @@ type SyncerInitializer func(opts Flags) (Syncer, error)
 
 var ErrSyncerInitializerRequired = errors.New("syncer initializer is required")
 
+// Exit codes returned by the sync command when the sync fails
+const (
+	ExitCodeFailure        = 1
+	ExitCodePartialFailure = 2
+)
+
+// ExitError is an error that carries the exit code that the application should terminate with
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // NewCommand returns a new cobra command that allows you to sync your github stars with a notion database
 func NewCommand(initializerFn SyncerInitializer) *cobra.Command {
 	command := &cobra.Command{
 		Use:   "sync",
 		Short: "Sync your github stars with a notion database",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
-			return validateRequiredFlags(cmd.Flags())
+			return ValidateRequiredFlags(cmd.Flags())
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return run(cmd, initializerFn)
 		},
 	}
 
-	command.Flags().StringP(FlagGitHubToken, "", os.Getenv("GITHUB_TOKEN"), "A github token to authenticate with the github api")
-	command.Flags().StringP(FlagNotionToken, "", os.Getenv("NOTION_TOKEN"), "A notion token to authenticate with the notion api")
-	command.Flags().StringP(FlagNotionDatabaseID, "", os.Getenv("NOTION_DATABASE_ID"), "The id of the notion database to sync with")
+	AddSyncerFlags(command.Flags())
+	command.Flags().Bool(FlagDryRun, false, "Print the changes that would be made to the notion database, without applying them")
+	command.Flags().StringP(FlagOutput, "o", OutputTable, "The format used to print the changes in dry run mode (table or json)")
+	command.Flags().String(FlagMaxDelete, envOrDefault("SYNC_MAX_DELETE", syncer.DefaultDeleteLimit), "The maximum number of pages that the sync can archive or move, as a number or a percentage of the existing pages (ex: 10 or 5%). When exceeded, the changes are printed and not applied. An empty value disables the limit")
+	command.Flags().Bool(FlagForce, false, "Apply the sync even when it archives or moves more pages than max-delete allows")
 
 	return command
 }
@@ -56,5 +81,44 @@ func run(cmd *cobra.Command, syncerInitializer SyncerInitializer) error {
 		return err
 	}
 
-	return syncerSvc.SyncStars(ctx, flags.NotionDatabaseID)
+	if flags.DryRun {
+		plan, err := syncerSvc.Plan(ctx, flags.NotionDatabaseID)
+		if err != nil {
+			return err
+		}
+
+		return printPlan(cmd.OutOrStdout(), plan, flags.Output)
+	}
+
+	result, err := syncerSvc.SyncStars(ctx, flags.NotionDatabaseID)
+
+	// the plan is printed so that the changes that were refused can be reviewed before forcing them
+	var limitErr *syncer.DeleteLimitError
+	if errors.As(err, &limitErr) {
+		if printErr := printPlan(cmd.OutOrStdout(), limitErr.Plan, flags.Output); printErr != nil {
+			return printErr
+		}
+
+		return &ExitError{Code: ExitCodeFailure, Err: fmt.Errorf("%w. Run with --%s to apply it", err, FlagForce)}
+	}
+
+	if result == nil {
+		return err
+	}
+
+	if printErr := printResult(cmd.OutOrStdout(), result); printErr != nil {
+		return printErr
+	}
+
+	if err == nil {
+		return nil
+	}
+
+	// when nothing was changed, the sync is a total failure. Otherwise, some changes were applied
+	// and the sync is only a partial failure, which is reported with a distinct exit code.
+	if result.Succeeded() == 0 {
+		return &ExitError{Code: ExitCodeFailure, Err: err}
+	}
+
+	return &ExitError{Code: ExitCodePartialFailure, Err: err}
 }
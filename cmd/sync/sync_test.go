@@ -1,12 +1,18 @@
 package sync_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/brpaz/github-stars-notion-sync/cmd/sync"
+	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
 	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
@@ -15,15 +21,29 @@ type MockSyncer struct {
 	mock.Mock
 }
 
-func (m *MockSyncer) SyncStars(ctx context.Context, databaseID string) error {
+func (m *MockSyncer) SyncStars(ctx context.Context, databaseID string) (*syncer.SyncResult, error) {
 	args := m.Called(ctx, databaseID)
-	return args.Error(0)
+	if result, ok := args.Get(0).(*syncer.SyncResult); ok {
+		return result, args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockSyncer) Plan(ctx context.Context, databaseID string) (*syncer.Plan, error) {
+	args := m.Called(ctx, databaseID)
+	if plan, ok := args.Get(0).(*syncer.Plan); ok {
+		return plan, args.Error(1)
+	}
+
+	return nil, args.Error(1)
 }
 
 // reset environment variables to make sure the tests run in a clean environment
 func resetEnv(t *testing.T) {
 	t.Helper()
 	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("GITHUB_USER", "")
 	t.Setenv("NOTION_TOKEN", "")
 	t.Setenv("NOTION_DATABASE_ID", "")
 }
@@ -48,6 +68,11 @@ func TestRun_WithMissingArgs_ReturnsError(t *testing.T) {
 			args:     []string{"--notion-token", "123", "--notion-database-id", "123"},
 			expected: "github-token is required",
 		},
+		{
+			name:     "should return error if star lists are synced without a github token",
+			args:     []string{"--github-user", "octocat", "--notion-token", "123", "--notion-database-id", "123", "--sync-lists"},
+			expected: "github-token is required to sync star lists",
+		},
 		{
 			name:     "should return error if notion token is not provided",
 			args:     []string{"--github-token", "123", "--notion-database-id", "123"},
@@ -86,10 +111,293 @@ func TestRun_WithValidArgs(t *testing.T) {
 		})
 		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"})
 
-		mockSyncer.On("SyncStars", context.Background(), "123").Return(nil)
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+	})
+
+	t.Run("passes the property mapping to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		mappingFile := filepath.Join(t.TempDir(), "mapping.json")
+		require.NoError(t, os.WriteFile(mappingFile, []byte(`{"title":"Repo","topics":"Labels"}`), 0o600))
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetArgs([]string{
+			"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123",
+			"--property-map-file", mappingFile,
+			"--property-map", "description=Summary,topics=Tags",
+		})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"title":       "Repo",
+			"description": "Summary",
+			"topics":      "Tags",
+		}, receivedFlags.PropertyMapping)
+	})
+
+	t.Run("passes the concurrency options to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--concurrency", "5", "--notion-rps", "2.5"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, 5, receivedFlags.Concurrency)
+		assert.Equal(t, 2.5, receivedFlags.NotionRPS)
+	})
+
+	t.Run("passes the retry options to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--max-retries", "5", "--retry-max-wait", "30s"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, 5, receivedFlags.MaxRetries)
+		assert.Equal(t, 30*time.Second, receivedFlags.RetryMaxWait)
+	})
+
+	t.Run("passes the incremental sync options to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--state-file", "/tmp/state.db", "--full-every", "12h"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/state.db", receivedFlags.StateFile)
+		assert.Equal(t, 12*time.Hour, receivedFlags.FullSyncInterval)
+	})
+
+	t.Run("syncs the stars of a github user without a github token", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "", "--github-user", "octocat", "--notion-token", "123", "--notion-database-id", "123"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
 		err := cmd.Execute()
 
 		require.NoError(t, err)
+		assert.Equal(t, "octocat", receivedFlags.GitHubUser)
+		assert.Empty(t, receivedFlags.GitHubToken)
+	})
+
+	t.Run("passes the additional github accounts to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--github-account", "octocat", "--github-account", "token:456"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, []sync.GitHubAccount{{User: "octocat"}, {Token: "456"}}, receivedFlags.GitHubAccounts)
+	})
+
+	t.Run("returns error with an empty github account", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return &MockSyncer{}, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--github-account", "token:"})
+
+		err := cmd.Execute()
+
+		assert.ErrorIs(t, err, sync.ErrEmptyGitHubAccount)
+	})
+
+	t.Run("passes the star lists options to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--sync-lists", "--lists-database-id", "456"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.True(t, receivedFlags.SyncLists)
+		assert.Equal(t, "456", receivedFlags.ListsDatabaseID)
+	})
+
+	t.Run("passes the readme option to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--sync-readme"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.True(t, receivedFlags.SyncReadme)
+	})
+
+	t.Run("passes the unstar policy to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--on-unstar", "move", "--archive-database-id", "456"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, "move", receivedFlags.OnUnstar)
+		assert.Equal(t, "456", receivedFlags.ArchiveDatabaseID)
+	})
+
+	t.Run("returns error with an invalid unstar policy", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return &MockSyncer{}, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--on-unstar", "delete"})
+
+		err := cmd.Execute()
+
+		assert.ErrorIs(t, err, sync.ErrInvalidOnUnstar)
+	})
+
+	t.Run("returns error when moving pages without an archive database", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return &MockSyncer{}, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--on-unstar", "move"})
+
+		err := cmd.Execute()
+
+		assert.ErrorIs(t, err, sync.ErrArchiveDatabaseRequired)
+	})
+
+	t.Run("passes the delete limit to the syncer", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--max-delete", "5%", "--force"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, &syncer.DeleteLimit{Percent: 5}, receivedFlags.MaxDelete)
+		assert.True(t, receivedFlags.Force)
+	})
+
+	t.Run("limits the deletions by default", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedFlags sync.Flags
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			receivedFlags = opts
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, &syncer.DeleteLimit{Percent: 10}, receivedFlags.MaxDelete)
+		assert.False(t, receivedFlags.Force)
+	})
+
+	t.Run("returns error with an invalid delete limit", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return &MockSyncer{}, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--max-delete", "-5"})
+
+		err := cmd.Execute()
+
+		assert.ErrorIs(t, err, sync.ErrInvalidMaxDelete)
 	})
 
 	t.Run("error", func(t *testing.T) {
@@ -101,9 +409,205 @@ func TestRun_WithValidArgs(t *testing.T) {
 		})
 		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"})
 
-		mockSyncer.On("SyncStars", context.Background(), "123").Return(errors.New("some-error"))
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(nil, errors.New("some-error"))
 		err := cmd.Execute()
 
 		require.Error(t, err)
 	})
+
+	t.Run("prints the sync result", func(t *testing.T) {
+		t.Parallel()
+
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return mockSyncer, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{Created: 3, Updated: 2, Archived: 1, Skipped: 4}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, "Sync finished: 3 created, 2 updated, 1 archived, 4 skipped, 0 failed.\n", out.String())
+	})
+
+	t.Run("returns partial failure exit code when some changes fail", func(t *testing.T) {
+		t.Parallel()
+
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"})
+
+		syncErr := errors.New("1 of 3 changes failed")
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{Created: 2, Failed: 1}, syncErr)
+		err := cmd.Execute()
+
+		var exitErr *sync.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, sync.ExitCodePartialFailure, exitErr.Code)
+		assert.ErrorIs(t, err, syncErr)
+	})
+
+	t.Run("returns failure exit code when all changes fail", func(t *testing.T) {
+		t.Parallel()
+
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return mockSyncer, nil
+		})
+		cmd.SetOut(bytes.NewBuffer([]byte{}))
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{Failed: 3}, errors.New("3 of 3 changes failed"))
+		err := cmd.Execute()
+
+		var exitErr *sync.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, sync.ExitCodeFailure, exitErr.Code)
+	})
+
+	t.Run("reports the pages that were skipped because they are malformed", func(t *testing.T) {
+		t.Parallel()
+
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return mockSyncer, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123"})
+
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(&syncer.SyncResult{
+			Created: 1,
+			Malformed: []syncer.MalformedPage{
+				{PageID: "page-1", PageURL: "https://www.notion.so/page-1", Reason: "property Repository ID has no valid repository id"},
+			},
+		}, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		assert.Equal(t, "Sync finished: 1 created, 0 updated, 0 archived, 0 skipped, 0 failed.\n"+
+			"Warning: 1 pages can't be matched with a repository and were skipped:\n"+
+			"  - https://www.notion.so/page-1: property Repository ID has no valid repository id\n", out.String())
+	})
+
+	t.Run("prints the plan and fails when the delete limit is exceeded", func(t *testing.T) {
+		t.Parallel()
+
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return mockSyncer, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--max-delete", "1"})
+
+		limitErr := &syncer.DeleteLimitError{
+			Plan: &syncer.Plan{
+				Changes: []syncer.Change{
+					{Action: syncer.ActionArchive, Name: "repo-1", PageID: "page-1"},
+					{Action: syncer.ActionArchive, Name: "repo-2", PageID: "page-2"},
+				},
+			},
+			Deletions: 2,
+			Existing:  2,
+			Limit:     syncer.DeleteLimit{Pages: 1},
+		}
+		mockSyncer.On("SyncStars", context.Background(), "123").Return(nil, limitErr)
+		err := cmd.Execute()
+
+		var exitErr *sync.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, sync.ExitCodeFailure, exitErr.Code)
+		assert.ErrorIs(t, err, limitErr)
+		assert.Contains(t, err.Error(), "--force")
+		assert.Contains(t, out.String(), "Plan: 0 to create, 0 to update, 2 to archive, 0 unchanged.\n")
+	})
+}
+
+func TestRun_DryRun(t *testing.T) {
+	t.Parallel()
+
+	mockPlan := &syncer.Plan{
+		DatabaseID: "123",
+		Changes: []syncer.Change{
+			{Action: syncer.ActionCreate, RepoID: 1, Name: "repo-1", URL: "https://github.com/user/repo-1"},
+			{Action: syncer.ActionUpdate, RepoID: 2, Name: "repo-2", PageID: "page-2", Properties: []string{"Description", "Topics"}},
+			{Action: syncer.ActionArchive, RepoID: 3, Name: "repo-3", PageID: "page-3"},
+		},
+		Skipped: 2,
+		Full:    true,
+	}
+
+	t.Run("prints the plan as a table", func(t *testing.T) {
+		t.Parallel()
+
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return mockSyncer, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--dry-run"})
+
+		mockSyncer.On("Plan", context.Background(), "123").Return(mockPlan, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		mockSyncer.AssertNotCalled(t, "SyncStars", mock.Anything, mock.Anything)
+
+		expected := "ACTION   REPOSITORY  PAGE    CHANGES\n" +
+			"create   repo-1      -       -\n" +
+			"update   repo-2      page-2  Description, Topics\n" +
+			"archive  repo-3      page-3  -\n" +
+			"\n" +
+			"Plan: 1 to create, 1 to update, 1 to archive, 2 unchanged.\n"
+		assert.Equal(t, expected, out.String())
+	})
+
+	t.Run("prints the plan as json", func(t *testing.T) {
+		t.Parallel()
+
+		mockSyncer := &MockSyncer{}
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return mockSyncer, nil
+		})
+		out := bytes.NewBuffer([]byte{})
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--dry-run", "--output", "json"})
+
+		mockSyncer.On("Plan", context.Background(), "123").Return(mockPlan, nil)
+		err := cmd.Execute()
+
+		require.NoError(t, err)
+		mockSyncer.AssertNotCalled(t, "SyncStars", mock.Anything, mock.Anything)
+		assert.JSONEq(t, `{
+			"database_id": "123",
+			"changes": [
+				{"action": "create", "repository_id": 1, "name": "repo-1", "url": "https://github.com/user/repo-1"},
+				{"action": "update", "repository_id": 2, "name": "repo-2", "page_id": "page-2", "properties": ["Description", "Topics"]},
+				{"action": "archive", "repository_id": 3, "name": "repo-3", "page_id": "page-3"}
+			],
+			"skipped": 2,
+			"full": true
+		}`, out.String())
+	})
+
+	t.Run("returns error with invalid output format", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := sync.NewCommand(func(opts sync.Flags) (sync.Syncer, error) {
+			return &MockSyncer{}, nil
+		})
+		cmd.SetArgs([]string{"--github-token", "123", "--notion-token", "123", "--notion-database-id", "123", "--dry-run", "--output", "xml"})
+
+		err := cmd.Execute()
+
+		require.ErrorIs(t, err, sync.ErrInvalidOutput)
+	})
 }
@@ -0,0 +1,156 @@
+// Package etag provides an http.RoundTripper that makes conditional requests using ETags.
+// When the server replies with "304 Not Modified", the cached response is returned instead, which allows
+// to reuse responses that didn't change without spending the GitHub rate limit.
+package etag
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/log"
+)
+
+// Entry is a cached response, identified by its ETag
+type Entry struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// Cache stores the responses of previous requests
+type Cache interface {
+	// ETag returns the cached response for the given key, if any
+	ETag(key string) (Entry, bool, error)
+	// SaveETag stores the response for the given key
+	SaveETag(key string, entry Entry) error
+}
+
+// Transport is a http.RoundTripper that sends the "If-None-Match" header with the ETag of the cached response
+// of GET requests, and reuses the cached response when the server replies with "304 Not Modified".
+type Transport struct {
+	Base  http.RoundTripper
+	Cache Cache
+	// Match, when set, selects the GET requests whose responses are cached, so that the cache doesn't grow with
+	// responses that are rarely requested again. The other requests are sent unchanged.
+	Match func(req *http.Request) bool
+}
+
+// NewTransport creates a new ETag transport that wraps the given base transport.
+// If base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, cache Cache) *Transport {
+	return &Transport{
+		Base:  base,
+		Cache: cache,
+	}
+}
+
+// RoundTrip executes the request, making it conditional when a cached response exists
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("If-None-Match") != "" || (t.Match != nil && !t.Match(req)) {
+		return t.base().RoundTrip(req)
+	}
+
+	ctx := req.Context()
+	key := cacheKey(req)
+
+	cached, found, err := t.Cache.ETag(key)
+	if err != nil {
+		log.Error(ctx, "error reading etag cache", log.String("error", err.Error()))
+		found = false
+	}
+
+	if found {
+		// a round tripper must not modify the original request
+		req = req.Clone(ctx)
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.base().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && resp.StatusCode == http.StatusNotModified {
+		log.Debug(ctx, "reusing cached response", log.String("url", req.URL.String()))
+		resp.Body.Close()
+
+		return cachedResponse(req, resp, cached), nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	err = t.Cache.SaveETag(key, Entry{
+		ETag:   etag,
+		Header: resp.Header.Clone(),
+		Body:   body,
+	})
+	if err != nil {
+		log.Error(ctx, "error saving etag cache", log.String("error", err.Error()))
+	}
+
+	return resp, nil
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+// cachedResponse builds a response from the cached entry. The headers of the "304 Not Modified" response,
+// like the current rate limits, take precedence over the cached ones.
+func cachedResponse(req *http.Request, notModified *http.Response, cached Entry) *http.Response {
+	header := cached.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	for key, values := range notModified.Header {
+		header[key] = values
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       req,
+	}
+}
+
+// cacheKey identifies a request by its URL and credentials, since the same URL returns different
+// content for different users. The credentials are hashed to avoid storing them.
+func cacheKey(req *http.Request) string {
+	key := req.URL.String()
+
+	if auth := req.Header.Get("Authorization"); auth != "" {
+		sum := sha256.Sum256([]byte(auth))
+		key = strings.Join([]string{key, hex.EncodeToString(sum[:8])}, "#")
+	}
+
+	return key
+}
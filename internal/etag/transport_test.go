@@ -0,0 +1,157 @@
+package etag_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/etag"
+)
+
+// memoryCache is an in-memory etag.Cache used for testing
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]etag.Entry
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: make(map[string]etag.Entry)}
+}
+
+func (c *memoryCache) ETag(key string) (etag.Entry, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	return entry, ok, nil
+}
+
+func (c *memoryCache) SaveETag(key string, entry etag.Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = entry
+	return nil
+}
+
+// newFakeGitHub creates a test server that replies with the given body and ETag, and with "304 Not Modified"
+// when the request carries the same ETag.
+func newFakeGitHub(t *testing.T, body string, etagValue string) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	receivedETags := make([]string, 0)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		receivedETags = append(receivedETags, r.Header.Get("If-None-Match"))
+		mu.Unlock()
+
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+
+		if r.Header.Get("If-None-Match") == etagValue {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etagValue)
+		w.Header().Set("Link", `<https://api.github.com/user/starred?page=2>; rel="next"`)
+		_, _ = w.Write([]byte(body))
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, &receivedETags
+}
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer token")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(body)
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reuses the cached response when the server replies with not modified", func(t *testing.T) {
+		t.Parallel()
+
+		server, receivedETags := newFakeGitHub(t, `[{"id":1}]`, `W/"abc"`)
+		client := &http.Client{Transport: etag.NewTransport(nil, newMemoryCache())}
+
+		firstResp, firstBody := get(t, client, server.URL+"/user/starred")
+		secondResp, secondBody := get(t, client, server.URL+"/user/starred")
+
+		assert.Equal(t, []string{"", `W/"abc"`}, *receivedETags)
+		assert.Equal(t, http.StatusOK, firstResp.StatusCode)
+		assert.Equal(t, http.StatusOK, secondResp.StatusCode)
+		assert.Equal(t, `[{"id":1}]`, firstBody)
+		assert.Equal(t, firstBody, secondBody)
+		assert.Equal(t, firstResp.Header.Get("Link"), secondResp.Header.Get("Link"))
+		assert.Equal(t, "4999", secondResp.Header.Get("X-RateLimit-Remaining"))
+	})
+
+	t.Run("caches responses per url", func(t *testing.T) {
+		t.Parallel()
+
+		server, receivedETags := newFakeGitHub(t, `[{"id":1}]`, `W/"abc"`)
+		client := &http.Client{Transport: etag.NewTransport(nil, newMemoryCache())}
+
+		get(t, client, server.URL+"/user/starred?page=1")
+		get(t, client, server.URL+"/user/starred?page=2")
+
+		assert.Equal(t, []string{"", ""}, *receivedETags)
+	})
+
+	t.Run("only caches the matching requests", func(t *testing.T) {
+		t.Parallel()
+
+		server, receivedETags := newFakeGitHub(t, `[{"id":1}]`, `W/"abc"`)
+		cache := newMemoryCache()
+		transport := etag.NewTransport(nil, cache)
+		transport.Match = func(req *http.Request) bool {
+			return strings.HasSuffix(req.URL.Path, "/starred")
+		}
+		client := &http.Client{Transport: transport}
+
+		for i := 0; i < 2; i++ {
+			get(t, client, server.URL+"/repos/brpaz/github-stars-notion-sync/readme")
+			get(t, client, server.URL+"/user/starred")
+		}
+
+		assert.Equal(t, []string{"", "", "", `W/"abc"`}, *receivedETags)
+		assert.Len(t, cache.entries, 1)
+	})
+
+	t.Run("does not make conditional requests for other methods", func(t *testing.T) {
+		t.Parallel()
+
+		server, receivedETags := newFakeGitHub(t, `{}`, `W/"abc"`)
+		client := &http.Client{Transport: etag.NewTransport(nil, newMemoryCache())}
+
+		for i := 0; i < 2; i++ {
+			resp, err := client.Post(server.URL+"/graphql", "application/json", nil)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}
+
+		assert.Equal(t, []string{"", ""}, *receivedETags)
+	})
+}
@@ -17,6 +17,11 @@ func Info(ctx context.Context, message string, attrs ...slog.Attr) {
 	slog.LogAttrs(ctx, slog.LevelInfo, message, attrs...)
 }
 
+// Warn logs a message with warning level
+func Warn(ctx context.Context, message string, attrs ...slog.Attr) {
+	slog.LogAttrs(ctx, slog.LevelWarn, message, attrs...)
+}
+
 // Error logs a message with error level
 func Error(ctx context.Context, message string, attrs ...slog.Attr) {
 	slog.LogAttrs(ctx, slog.LevelError, message, attrs...)
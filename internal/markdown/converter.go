@@ -0,0 +1,393 @@
+// Package markdown converts markdown documents, like the README of a repository, to notion blocks.
+// Notion doesn't support every markdown construct, so raw html is dropped, except for its images,
+// and the blocks nested deeper than the notion api accepts are flattened.
+package markdown
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	// MaxBlocksPerRequest is the maximum number of blocks that can be appended to a page in a single request
+	MaxBlocksPerRequest = 100
+	// maxNestingDepth is the number of levels of children that the notion api accepts in a single request
+	maxNestingDepth = 2
+)
+
+// htmlImagePattern matches the source of the images of raw html, often used in READMEs to center logos and badges
+var htmlImagePattern = regexp.MustCompile(`(?i)<img\s[^>]*?src\s*=\s*["']([^"']+)["']`)
+
+// Converter converts markdown documents to notion blocks
+type Converter struct {
+	// LinkBaseURL is used to resolve relative links. Relative links are kept as plain text when it is nil.
+	LinkBaseURL *url.URL
+	// ImageBaseURL is used to resolve relative image sources. Images with relative sources are dropped when it is nil.
+	ImageBaseURL *url.URL
+	// OnTruncate, when set, is called for each block that has more children than notion accepts in a single
+	// request, like a long nested list or a table with many rows, with the number of children that are dropped
+	OnTruncate func(blockType notionapi.BlockType, dropped int)
+}
+
+// Convert parses the given markdown document and returns its notion blocks
+func (c Converter) Convert(source []byte) []notionapi.Block {
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM))
+	document := md.Parser().Parse(text.NewReader(source))
+
+	r := &renderer{converter: c, source: source}
+
+	return r.blocks(document, 0)
+}
+
+// renderer holds the state of the conversion of a single document
+type renderer struct {
+	converter Converter
+	source    []byte
+}
+
+// blocks converts the children of the given node. The depth is the nesting level of the converted blocks.
+func (r *renderer) blocks(parent ast.Node, depth int) []notionapi.Block {
+	blocks := make([]notionapi.Block, 0)
+	for node := parent.FirstChild(); node != nil; node = node.NextSibling() {
+		blocks = append(blocks, r.block(node, depth)...)
+	}
+
+	return blocks
+}
+
+func (r *renderer) block(node ast.Node, depth int) []notionapi.Block {
+	switch n := node.(type) {
+	case *ast.Heading:
+		richText, images := r.inline(n)
+		return append(headingBlocks(n.Level, richText), images...)
+	case *ast.Paragraph, *ast.TextBlock:
+		richText, images := r.inline(n)
+		return append(paragraphBlocks(richText), images...)
+	case *ast.List:
+		return r.list(n, depth)
+	case *ast.FencedCodeBlock:
+		return codeBlocks(r.lines(n), codeLanguage(string(n.Language(r.source))))
+	case *ast.CodeBlock:
+		return codeBlocks(r.lines(n), codeLanguage(""))
+	case *ast.Blockquote:
+		return r.quote(n, depth)
+	case *ast.ThematicBreak:
+		return []notionapi.Block{&notionapi.DividerBlock{BasicBlock: basicBlock(notionapi.BlockTypeDivider)}}
+	case *ast.HTMLBlock:
+		html := r.lines(n)
+		if n.HasClosure() {
+			html += string(n.ClosureLine.Value(r.source))
+		}
+
+		return r.htmlImages(html)
+	case *east.Table:
+		return r.table(n, depth)
+	}
+
+	return nil
+}
+
+// childrenDepth returns the depth of the children of a block, and whether they can be nested in the block.
+// When the block is too deep, its children are flattened after it, at its own depth.
+func childrenDepth(depth int) (int, bool) {
+	if depth < maxNestingDepth {
+		return depth + 1, true
+	}
+
+	return depth, false
+}
+
+// withChildren nests the children in the block with the given setter, or flattens them after the block
+// when they can't be nested
+func (r *renderer) withChildren(block notionapi.Block, children []notionapi.Block, nested bool, setChildren func(notionapi.Blocks)) []notionapi.Block {
+	if !nested {
+		return append([]notionapi.Block{block}, children...)
+	}
+
+	if len(children) > 0 {
+		// the notion api doesn't accept more children in a single request
+		if len(children) > MaxBlocksPerRequest {
+			r.truncated(block.GetType(), len(children)-MaxBlocksPerRequest)
+			children = children[:MaxBlocksPerRequest]
+		}
+
+		setChildren(children)
+	}
+
+	return []notionapi.Block{block}
+}
+
+func (r *renderer) list(list *ast.List, depth int) []notionapi.Block {
+	blocks := make([]notionapi.Block, 0)
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		blocks = append(blocks, r.listItem(item, list.IsOrdered(), depth)...)
+	}
+
+	return blocks
+}
+
+func (r *renderer) listItem(item ast.Node, ordered bool, depth int) []notionapi.Block {
+	richText := make([]notionapi.RichText, 0)
+	children := make([]notionapi.Block, 0)
+	childDepth, nested := childrenDepth(depth)
+
+	var checkBox *east.TaskCheckBox
+
+	rest := item.FirstChild()
+	if rest != nil && (rest.Kind() == ast.KindParagraph || rest.Kind() == ast.KindTextBlock) {
+		checkBox, _ = rest.FirstChild().(*east.TaskCheckBox)
+
+		var images []notionapi.Block
+		richText, images = r.inline(rest)
+		children = append(children, images...)
+		rest = rest.NextSibling()
+	}
+
+	for node := rest; node != nil; node = node.NextSibling() {
+		children = append(children, r.block(node, childDepth)...)
+	}
+
+	richText = truncateRichText(richText)
+
+	switch {
+	case checkBox != nil:
+		block := &notionapi.ToDoBlock{
+			BasicBlock: basicBlock(notionapi.BlockTypeToDo),
+			ToDo:       notionapi.ToDo{RichText: richText, Checked: checkBox.IsChecked},
+		}
+
+		return r.withChildren(block, children, nested, func(children notionapi.Blocks) { block.ToDo.Children = children })
+	case ordered:
+		block := &notionapi.NumberedListItemBlock{
+			BasicBlock:       basicBlock(notionapi.BlockTypeNumberedListItem),
+			NumberedListItem: notionapi.ListItem{RichText: richText},
+		}
+
+		return r.withChildren(block, children, nested, func(children notionapi.Blocks) { block.NumberedListItem.Children = children })
+	default:
+		block := &notionapi.BulletedListItemBlock{
+			BasicBlock:       basicBlock(notionapi.BlockTypeBulletedListItem),
+			BulletedListItem: notionapi.ListItem{RichText: richText},
+		}
+
+		return r.withChildren(block, children, nested, func(children notionapi.Blocks) { block.BulletedListItem.Children = children })
+	}
+}
+
+func (r *renderer) quote(quote *ast.Blockquote, depth int) []notionapi.Block {
+	richText := make([]notionapi.RichText, 0)
+	children := make([]notionapi.Block, 0)
+	childDepth, nested := childrenDepth(depth)
+
+	rest := quote.FirstChild()
+	if rest != nil && rest.Kind() == ast.KindParagraph {
+		var images []notionapi.Block
+		richText, images = r.inline(rest)
+		children = append(children, images...)
+		rest = rest.NextSibling()
+	}
+
+	for node := rest; node != nil; node = node.NextSibling() {
+		children = append(children, r.block(node, childDepth)...)
+	}
+
+	block := &notionapi.QuoteBlock{
+		BasicBlock: basicBlock(notionapi.BlockQuote),
+		Quote:      notionapi.Quote{RichText: truncateRichText(richText)},
+	}
+
+	return r.withChildren(block, children, nested, func(children notionapi.Blocks) { block.Quote.Children = children })
+}
+
+func (r *renderer) table(table *east.Table, depth int) []notionapi.Block {
+	// the rows of a table must be created with it, so tables can't be flattened
+	if _, nested := childrenDepth(depth); !nested {
+		return nil
+	}
+
+	rows := make([]*notionapi.TableRowBlock, 0)
+	width := 0
+	dropped := 0
+
+	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
+		// the rows must be created with the table, and notion doesn't accept more children in a single request
+		if len(rows) == MaxBlocksPerRequest {
+			dropped++
+			continue
+		}
+
+		cells := make([][]notionapi.RichText, 0)
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			// images can't be displayed in table cells
+			richText, _ := r.inline(cell)
+			cells = append(cells, truncateRichText(richText))
+		}
+
+		width = max(width, len(cells))
+		rows = append(rows, &notionapi.TableRowBlock{
+			BasicBlock: basicBlock(notionapi.BlockTypeTableRowBlock),
+			TableRow:   notionapi.TableRow{Cells: cells},
+		})
+	}
+
+	if width == 0 {
+		return nil
+	}
+
+	if dropped > 0 {
+		r.truncated(notionapi.BlockTypeTableBlock, dropped)
+	}
+
+	// every row must have as many cells as the table width
+	children := make(notionapi.Blocks, len(rows))
+	for i, row := range rows {
+		for len(row.TableRow.Cells) < width {
+			row.TableRow.Cells = append(row.TableRow.Cells, []notionapi.RichText{})
+		}
+
+		children[i] = row
+	}
+
+	_, hasHeader := table.FirstChild().(*east.TableHeader)
+
+	return []notionapi.Block{
+		&notionapi.TableBlock{
+			BasicBlock: basicBlock(notionapi.BlockTypeTableBlock),
+			Table: notionapi.Table{
+				TableWidth:      width,
+				HasColumnHeader: hasHeader,
+				Children:        children,
+			},
+		},
+	}
+}
+
+// truncated reports the children of a block that are dropped, because there are more than notion accepts
+func (r *renderer) truncated(blockType notionapi.BlockType, dropped int) {
+	if r.converter.OnTruncate != nil {
+		r.converter.OnTruncate(blockType, dropped)
+	}
+}
+
+// htmlImages returns the image blocks of the images found in raw html. The rest of the html is dropped.
+func (r *renderer) htmlImages(html string) []notionapi.Block {
+	blocks := make([]notionapi.Block, 0)
+	for _, match := range htmlImagePattern.FindAllStringSubmatch(html, -1) {
+		if image := r.image(match[1]); image != nil {
+			blocks = append(blocks, image)
+		}
+	}
+
+	return blocks
+}
+
+// image builds an image block with the given source, or returns nil when the source can't be resolved
+func (r *renderer) image(source string) notionapi.Block {
+	imageURL := resolveURL(r.converter.ImageBaseURL, source)
+	if imageURL == "" {
+		return nil
+	}
+
+	return &notionapi.ImageBlock{
+		BasicBlock: basicBlock(notionapi.BlockTypeImage),
+		Image: notionapi.Image{
+			Type:     notionapi.FileTypeExternal,
+			External: &notionapi.FileObject{URL: imageURL},
+		},
+	}
+}
+
+// lines returns the raw content of a block
+func (r *renderer) lines(node ast.Node) string {
+	var sb strings.Builder
+
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		sb.Write(line.Value(r.source))
+	}
+
+	return sb.String()
+}
+
+// resolveURL resolves a link or image reference against the base url. Only web urls are kept, since references
+// relative to the document can't be opened from notion.
+func resolveURL(base *url.URL, reference string) string {
+	u, err := url.Parse(strings.TrimSpace(reference))
+	if err != nil {
+		return ""
+	}
+
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+
+	return u.String()
+}
+
+func basicBlock(blockType notionapi.BlockType) notionapi.BasicBlock {
+	return notionapi.BasicBlock{
+		Object: notionapi.ObjectTypeBlock,
+		Type:   blockType,
+	}
+}
+
+func headingBlocks(level int, richText []notionapi.RichText) []notionapi.Block {
+	if len(richText) == 0 {
+		return []notionapi.Block{}
+	}
+
+	heading := notionapi.Heading{RichText: truncateRichText(richText)}
+
+	// notion only has three levels of headings
+	switch level {
+	case 1:
+		return []notionapi.Block{&notionapi.Heading1Block{BasicBlock: basicBlock(notionapi.BlockTypeHeading1), Heading1: heading}}
+	case 2:
+		return []notionapi.Block{&notionapi.Heading2Block{BasicBlock: basicBlock(notionapi.BlockTypeHeading2), Heading2: heading}}
+	default:
+		return []notionapi.Block{&notionapi.Heading3Block{BasicBlock: basicBlock(notionapi.BlockTypeHeading3), Heading3: heading}}
+	}
+}
+
+// paragraphBlocks builds the paragraphs that hold the given rich text. Long text is split in several paragraphs,
+// since notion limits the number of rich text objects of a block.
+func paragraphBlocks(richText []notionapi.RichText) []notionapi.Block {
+	blocks := make([]notionapi.Block, 0, 1)
+	for _, chunk := range chunkRichText(richText) {
+		blocks = append(blocks, &notionapi.ParagraphBlock{
+			BasicBlock: basicBlock(notionapi.BlockTypeParagraph),
+			Paragraph:  notionapi.Paragraph{RichText: chunk},
+		})
+	}
+
+	return blocks
+}
+
+// codeBlocks builds the code blocks that hold the given code. Long code is split in several blocks.
+func codeBlocks(code string, language string) []notionapi.Block {
+	w := &richTextWriter{}
+	w.write(strings.TrimSuffix(code, "\n"), inlineStyle{})
+
+	blocks := make([]notionapi.Block, 0, 1)
+	for _, chunk := range chunkRichText(w.richText) {
+		blocks = append(blocks, &notionapi.CodeBlock{
+			BasicBlock: basicBlock(notionapi.BlockTypeCode),
+			Code:       notionapi.Code{RichText: chunk, Language: language},
+		})
+	}
+
+	return blocks
+}
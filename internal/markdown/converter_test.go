@@ -0,0 +1,157 @@
+package markdown_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/markdown"
+)
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+
+	u, err := url.Parse(rawURL)
+	require.NoError(t, err)
+
+	return u
+}
+
+func newConverter(t *testing.T) markdown.Converter {
+	t.Helper()
+
+	return markdown.Converter{
+		LinkBaseURL:  mustParseURL(t, "https://github.com/octocat/hello-world/blob/main/README.md"),
+		ImageBaseURL: mustParseURL(t, "https://raw.githubusercontent.com/octocat/hello-world/main/README.md"),
+	}
+}
+
+func TestConverter_Convert(t *testing.T) {
+	t.Run("converts headings and paragraphs", func(t *testing.T) {
+		blocks := newConverter(t).Convert([]byte("# Hello\n\n## Usage\n\n#### Details\n\nSome **bold** and *italic* `code` with a [link](docs/usage.md).\n"))
+
+		require.Len(t, blocks, 4)
+		assert.Equal(t, "Hello", blocks[0].(*notionapi.Heading1Block).Heading1.RichText[0].Text.Content)
+		assert.Equal(t, "Usage", blocks[1].(*notionapi.Heading2Block).Heading2.RichText[0].Text.Content)
+		assert.Equal(t, "Details", blocks[2].(*notionapi.Heading3Block).Heading3.RichText[0].Text.Content)
+
+		richText := blocks[3].(*notionapi.ParagraphBlock).Paragraph.RichText
+		require.Len(t, richText, 9)
+		assert.Equal(t, "bold", richText[1].Text.Content)
+		assert.True(t, richText[1].Annotations.Bold)
+		assert.True(t, richText[3].Annotations.Italic)
+		assert.True(t, richText[5].Annotations.Code)
+		assert.Equal(t, "link", richText[7].Text.Content)
+		assert.Equal(t, "https://github.com/octocat/hello-world/blob/main/docs/usage.md", richText[7].Text.Link.Url)
+	})
+
+	t.Run("keeps relative links as plain text without a base url", func(t *testing.T) {
+		blocks := markdown.Converter{}.Convert([]byte("See the [usage](#usage).\n"))
+
+		require.Len(t, blocks, 1)
+		richText := blocks[0].(*notionapi.ParagraphBlock).Paragraph.RichText
+		require.Len(t, richText, 1)
+		assert.Equal(t, "See the usage.", richText[0].Text.Content)
+		assert.Nil(t, richText[0].Text.Link)
+	})
+
+	t.Run("converts images to image blocks", func(t *testing.T) {
+		blocks := newConverter(t).Convert([]byte("[![build](https://img.shields.io/badge/build-passing-green)](https://ci.example.com)\n\n<p align=\"center\"><img src=\"docs/logo.png\" width=\"200\"></p>\n"))
+
+		require.Len(t, blocks, 2)
+		assert.Equal(t, "https://img.shields.io/badge/build-passing-green", blocks[0].(*notionapi.ImageBlock).Image.External.URL)
+		assert.Equal(t, "https://raw.githubusercontent.com/octocat/hello-world/main/docs/logo.png", blocks[1].(*notionapi.ImageBlock).Image.External.URL)
+	})
+
+	t.Run("converts lists", func(t *testing.T) {
+		blocks := newConverter(t).Convert([]byte("- one\n  - nested\n    - deeper\n      - deepest\n- [x] done\n\n1. first\n2. second\n"))
+
+		require.Len(t, blocks, 4)
+
+		item := blocks[0].(*notionapi.BulletedListItemBlock)
+		assert.Equal(t, "one", item.BulletedListItem.RichText[0].Text.Content)
+		require.Len(t, item.BulletedListItem.Children, 1)
+
+		nested := item.BulletedListItem.Children[0].(*notionapi.BulletedListItemBlock)
+		require.Len(t, nested.BulletedListItem.Children, 2, "blocks deeper than the nesting limit are flattened")
+		deeper := nested.BulletedListItem.Children[0].(*notionapi.BulletedListItemBlock)
+		assert.Empty(t, deeper.BulletedListItem.Children)
+		assert.Equal(t, "deepest", nested.BulletedListItem.Children[1].(*notionapi.BulletedListItemBlock).BulletedListItem.RichText[0].Text.Content)
+
+		todo := blocks[1].(*notionapi.ToDoBlock)
+		assert.True(t, todo.ToDo.Checked)
+		assert.Equal(t, "done", todo.ToDo.RichText[0].Text.Content)
+
+		assert.Equal(t, "first", blocks[2].(*notionapi.NumberedListItemBlock).NumberedListItem.RichText[0].Text.Content)
+		assert.Equal(t, "second", blocks[3].(*notionapi.NumberedListItemBlock).NumberedListItem.RichText[0].Text.Content)
+	})
+
+	t.Run("converts code blocks", func(t *testing.T) {
+		blocks := newConverter(t).Convert([]byte("```sh\nmake build\n```\n\n```unknown\nfoo\n```\n\n    indented\n"))
+
+		require.Len(t, blocks, 3)
+		code := blocks[0].(*notionapi.CodeBlock).Code
+		assert.Equal(t, "shell", code.Language)
+		assert.Equal(t, "make build", code.RichText[0].Text.Content)
+		assert.Equal(t, "plain text", blocks[1].(*notionapi.CodeBlock).Code.Language)
+		assert.Equal(t, "indented", blocks[2].(*notionapi.CodeBlock).Code.RichText[0].Text.Content)
+	})
+
+	t.Run("splits long text", func(t *testing.T) {
+		code := strings.Repeat("a", markdown.MaxRichTextLength*2+10)
+		blocks := newConverter(t).Convert([]byte("```\n" + code + "\n```\n"))
+
+		require.Len(t, blocks, 1)
+		richText := blocks[0].(*notionapi.CodeBlock).Code.RichText
+		require.Len(t, richText, 3)
+		assert.Len(t, richText[0].Text.Content, markdown.MaxRichTextLength)
+		assert.Len(t, richText[2].Text.Content, 10)
+	})
+
+	t.Run("converts tables, quotes and dividers", func(t *testing.T) {
+		blocks := newConverter(t).Convert([]byte("> quoted\n\n---\n\n| Name | Value |\n|------|-------|\n| a | 1 |\n| b |\n"))
+
+		require.Len(t, blocks, 3)
+		assert.Equal(t, "quoted", blocks[0].(*notionapi.QuoteBlock).Quote.RichText[0].Text.Content)
+		assert.IsType(t, &notionapi.DividerBlock{}, blocks[1])
+
+		table := blocks[2].(*notionapi.TableBlock).Table
+		assert.Equal(t, 2, table.TableWidth)
+		assert.True(t, table.HasColumnHeader)
+		require.Len(t, table.Children, 3)
+		for _, row := range table.Children {
+			assert.Len(t, row.(*notionapi.TableRowBlock).TableRow.Cells, 2)
+		}
+	})
+
+	t.Run("reports the children that don't fit in a single request", func(t *testing.T) {
+		var source strings.Builder
+		source.WriteString("- Items\n")
+		for i := 0; i < 120; i++ {
+			source.WriteString("  - item\n")
+		}
+
+		source.WriteString("\n| Name |\n|------|\n")
+		for i := 0; i < 110; i++ {
+			source.WriteString("| row |\n")
+		}
+
+		truncated := make(map[notionapi.BlockType]int)
+		converter := newConverter(t)
+		converter.OnTruncate = func(blockType notionapi.BlockType, dropped int) {
+			truncated[blockType] += dropped
+		}
+
+		blocks := converter.Convert([]byte(source.String()))
+
+		require.Len(t, blocks, 2)
+		assert.Len(t, blocks[0].(*notionapi.BulletedListItemBlock).BulletedListItem.Children, markdown.MaxBlocksPerRequest)
+		assert.Len(t, blocks[1].(*notionapi.TableBlock).Table.Children, markdown.MaxBlocksPerRequest)
+		// the header row counts as a row of the table
+		assert.Equal(t, map[notionapi.BlockType]int{notionapi.BlockTypeBulletedListItem: 20, notionapi.BlockTypeTableBlock: 11}, truncated)
+	})
+}
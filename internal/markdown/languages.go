@@ -0,0 +1,78 @@
+package markdown
+
+import "strings"
+
+// notionPlainText is the language of the code blocks whose language is unknown
+const notionPlainText = "plain text"
+
+// notionLanguages are the languages supported by the code blocks of notion
+var notionLanguages = map[string]bool{
+	"abap": true, "arduino": true, "bash": true, "basic": true, "c": true, "clojure": true, "coffeescript": true,
+	"c++": true, "c#": true, "css": true, "dart": true, "diff": true, "docker": true, "elixir": true, "elm": true,
+	"erlang": true, "flow": true, "fortran": true, "f#": true, "gherkin": true, "glsl": true, "go": true,
+	"graphql": true, "groovy": true, "haskell": true, "html": true, "java": true, "javascript": true, "json": true,
+	"julia": true, "kotlin": true, "latex": true, "less": true, "lisp": true, "livescript": true, "lua": true,
+	"makefile": true, "markdown": true, "markup": true, "matlab": true, "mermaid": true, "nix": true,
+	"objective-c": true, "ocaml": true, "pascal": true, "perl": true, "php": true, "plain text": true,
+	"powershell": true, "prolog": true, "protobuf": true, "python": true, "r": true, "reason": true, "ruby": true,
+	"rust": true, "sass": true, "scala": true, "scheme": true, "scss": true, "shell": true, "solidity": true,
+	"sql": true, "swift": true, "typescript": true, "vb.net": true, "verilog": true, "vhdl": true,
+	"visual basic": true, "webassembly": true, "xml": true, "yaml": true,
+}
+
+// languageAliases maps the common names of the info string of fenced code blocks to the notion languages
+var languageAliases = map[string]string{
+	"sh":            "shell",
+	"zsh":           "shell",
+	"console":       "shell",
+	"shell-session": "shell",
+	"js":            "javascript",
+	"jsx":           "javascript",
+	"mjs":           "javascript",
+	"ts":            "typescript",
+	"tsx":           "typescript",
+	"py":            "python",
+	"rb":            "ruby",
+	"rs":            "rust",
+	"golang":        "go",
+	"cpp":           "c++",
+	"cs":            "c#",
+	"csharp":        "c#",
+	"fsharp":        "f#",
+	"kt":            "kotlin",
+	"yml":           "yaml",
+	"md":            "markdown",
+	"dockerfile":    "docker",
+	"make":          "makefile",
+	"ps1":           "powershell",
+	"pwsh":          "powershell",
+	"proto":         "protobuf",
+	"hs":            "haskell",
+	"ex":            "elixir",
+	"exs":           "elixir",
+	"objc":          "objective-c",
+	"tex":           "latex",
+	"wasm":          "webassembly",
+	"text":          notionPlainText,
+	"txt":           notionPlainText,
+}
+
+// codeLanguage returns the notion language of the info string of a fenced code block
+func codeLanguage(info string) string {
+	// the info string may have attributes after the language, like "go title=main.go"
+	fields := strings.Fields(strings.ToLower(info))
+	if len(fields) == 0 {
+		return notionPlainText
+	}
+
+	language := fields[0]
+	if alias, ok := languageAliases[language]; ok {
+		return alias
+	}
+
+	if notionLanguages[language] {
+		return language
+	}
+
+	return notionPlainText
+}
@@ -0,0 +1,187 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jomei/notionapi"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+)
+
+const (
+	// MaxRichTextLength is the maximum number of characters of the content of a rich text object
+	MaxRichTextLength = 2000
+	// maxRichTextElements is the maximum number of rich text objects of a block
+	maxRichTextElements = 100
+)
+
+// lineBreakPattern matches the html line breaks, the only inline html that is kept
+var lineBreakPattern = regexp.MustCompile(`(?i)^<br\s*/?>$`)
+
+// inlineStyle holds the annotations and the link applied to a run of text
+type inlineStyle struct {
+	bold          bool
+	italic        bool
+	strikethrough bool
+	code          bool
+	link          string
+}
+
+// richTextWriter builds a list of rich text objects. Consecutive runs of text with the same style are merged,
+// and split when they exceed the length limit of notion.
+type richTextWriter struct {
+	richText []notionapi.RichText
+	styles   []inlineStyle
+}
+
+func (w *richTextWriter) write(content string, style inlineStyle) {
+	if content == "" {
+		return
+	}
+
+	if last := len(w.richText) - 1; last >= 0 && w.styles[last] == style {
+		content = w.richText[last].Text.Content + content
+		w.richText = w.richText[:last]
+		w.styles = w.styles[:last]
+	}
+
+	for _, chunk := range splitText(content, MaxRichTextLength) {
+		w.richText = append(w.richText, buildRichText(chunk, style))
+		w.styles = append(w.styles, style)
+	}
+}
+
+// inline converts the inline content of a node to rich text. Images can't be inlined in notion, so they
+// are returned as separate blocks.
+func (r *renderer) inline(node ast.Node) ([]notionapi.RichText, []notionapi.Block) {
+	w := &richTextWriter{richText: make([]notionapi.RichText, 0)}
+	images := r.walkInline(node, inlineStyle{}, w)
+
+	return w.richText, images
+}
+
+func (r *renderer) walkInline(parent ast.Node, style inlineStyle, w *richTextWriter) []notionapi.Block {
+	images := make([]notionapi.Block, 0)
+
+	for node := parent.FirstChild(); node != nil; node = node.NextSibling() {
+		switch n := node.(type) {
+		case *ast.Text:
+			w.write(string(n.Segment.Value(r.source)), style)
+			if n.HardLineBreak() {
+				w.write("\n", style)
+			} else if n.SoftLineBreak() {
+				w.write(" ", style)
+			}
+		case *ast.String:
+			w.write(string(n.Value), style)
+		case *ast.CodeSpan:
+			codeStyle := style
+			codeStyle.code = true
+			images = append(images, r.walkInline(n, codeStyle, w)...)
+		case *ast.Emphasis:
+			emphasisStyle := style
+			if n.Level >= 2 {
+				emphasisStyle.bold = true
+			} else {
+				emphasisStyle.italic = true
+			}
+
+			images = append(images, r.walkInline(n, emphasisStyle, w)...)
+		case *east.Strikethrough:
+			strikethroughStyle := style
+			strikethroughStyle.strikethrough = true
+			images = append(images, r.walkInline(n, strikethroughStyle, w)...)
+		case *ast.Link:
+			linkStyle := style
+			linkStyle.link = resolveURL(r.converter.LinkBaseURL, string(n.Destination))
+			images = append(images, r.walkInline(n, linkStyle, w)...)
+		case *ast.AutoLink:
+			linkStyle := style
+			linkStyle.link = resolveURL(r.converter.LinkBaseURL, string(n.URL(r.source)))
+			w.write(string(n.Label(r.source)), linkStyle)
+		case *ast.Image:
+			if image := r.image(string(n.Destination)); image != nil {
+				images = append(images, image)
+			}
+		case *ast.RawHTML:
+			var sb strings.Builder
+			for i := 0; i < n.Segments.Len(); i++ {
+				segment := n.Segments.At(i)
+				sb.Write(segment.Value(r.source))
+			}
+
+			html := sb.String()
+			if lineBreakPattern.MatchString(html) {
+				w.write("\n", style)
+			}
+
+			images = append(images, r.htmlImages(html)...)
+		case *east.TaskCheckBox:
+			// the check box is converted to a to do block by the list item
+		default:
+			images = append(images, r.walkInline(n, style, w)...)
+		}
+	}
+
+	return images
+}
+
+func buildRichText(content string, style inlineStyle) notionapi.RichText {
+	richText := notionapi.RichText{
+		Type: notionapi.ObjectTypeText,
+		Text: &notionapi.Text{Content: content},
+	}
+
+	if style.link != "" {
+		richText.Text.Link = &notionapi.Link{Url: style.link}
+	}
+
+	if style.bold || style.italic || style.strikethrough || style.code {
+		richText.Annotations = &notionapi.Annotations{
+			Bold:          style.bold,
+			Italic:        style.italic,
+			Strikethrough: style.strikethrough,
+			Code:          style.code,
+		}
+	}
+
+	return richText
+}
+
+// splitText splits the content in chunks of at most size characters
+func splitText(content string, size int) []string {
+	chunks := make([]string, 0, 1)
+
+	runes := []rune(content)
+	for len(runes) > size {
+		chunks = append(chunks, string(runes[:size]))
+		runes = runes[size:]
+	}
+
+	return append(chunks, string(runes))
+}
+
+// chunkRichText splits the rich text in groups that fit in a single block
+func chunkRichText(richText []notionapi.RichText) [][]notionapi.RichText {
+	chunks := make([][]notionapi.RichText, 0, 1)
+	for len(richText) > maxRichTextElements {
+		chunks = append(chunks, richText[:maxRichTextElements])
+		richText = richText[maxRichTextElements:]
+	}
+
+	if len(richText) > 0 {
+		chunks = append(chunks, richText)
+	}
+
+	return chunks
+}
+
+// truncateRichText drops the rich text that doesn't fit in a single block
+func truncateRichText(richText []notionapi.RichText) []notionapi.RichText {
+	if len(richText) > maxRichTextElements {
+		return richText[:maxRichTextElements]
+	}
+
+	return richText
+}
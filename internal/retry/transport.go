@@ -0,0 +1,272 @@
+// Package retry provides an http.RoundTripper that retries requests that failed because of transient errors,
+// like rate limits or unavailable servers, using jittered exponential backoff.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/log"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultMinWait    = 1 * time.Second
+	DefaultMaxWait    = 1 * time.Minute
+)
+
+// Config holds the settings of the retry transport
+type Config struct {
+	// MaxRetries is the maximum number of times a request is retried. Zero disables the retries.
+	MaxRetries int
+	// MinWait is the base wait time of the exponential backoff
+	MinWait time.Duration
+	// MaxWait is the maximum time to wait before retrying a request. When the server asks to wait longer than this,
+	// the request is not retried.
+	MaxWait time.Duration
+}
+
+// DefaultConfig returns the default retry settings
+func DefaultConfig() Config {
+	return Config{
+		MaxRetries: DefaultMaxRetries,
+		MinWait:    DefaultMinWait,
+		MaxWait:    DefaultMaxWait,
+	}
+}
+
+// Limiter delays the requests to respect a rate limit, like a rate.Limiter
+type Limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// Transport is a http.RoundTripper that retries requests that failed with transient errors.
+// It retries "429 Too Many Requests" responses and the GitHub rate limit errors, that are returned with a 403
+// status code, since the server didn't process those requests. Network errors and 5xx responses are only retried
+// for GET and HEAD requests, because the server may have applied a write before failing, except for the network
+// errors raised before the request was sent.
+type Transport struct {
+	Base   http.RoundTripper
+	Config Config
+	// Limiter, when set, is waited before each retry, so that the retries count against the same rate limit
+	// as the requests made by the client
+	Limiter Limiter
+	// ReadOnly, when set, tells if a request sent with another method than GET or HEAD only reads data, like the
+	// searches sent with POST, so that it is retried like a GET request
+	ReadOnly func(req *http.Request) bool
+}
+
+// NewTransport creates a new retry transport that wraps the given base transport.
+// If base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, config Config) *Transport {
+	return &Transport{
+		Base:   base,
+		Config: config,
+	}
+}
+
+// RoundTrip executes the request, retrying it when it fails with a transient error
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attemptReq := req
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base().RoundTrip(attemptReq)
+
+		if attempt >= t.Config.MaxRetries || !canReplay(req) {
+			return resp, err
+		}
+
+		wait, retry := t.shouldRetry(req, resp, err, attempt)
+		if !retry {
+			return resp, err
+		}
+
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		log.Debug(ctx, "retrying request",
+			log.String("url", req.URL.String()),
+			log.Int("attempt", attempt+1),
+			log.String("wait", wait.String()),
+		)
+
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+
+		if t.Limiter != nil {
+			if err := t.Limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		// a round tripper must not modify the original request, so each retry uses a copy with a fresh body
+		attemptReq = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			attemptReq.Body = body
+		}
+	}
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base == nil {
+		return http.DefaultTransport
+	}
+
+	return t.Base
+}
+
+// shouldRetry checks if a request must be retried, and returns the time to wait before retrying it
+func (t *Transport) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) (time.Duration, bool) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, false
+		}
+
+		if !t.isIdempotent(req) && !notSent(err) {
+			return 0, false
+		}
+
+		return t.backoff(attempt), true
+	}
+
+	if isServerError(resp) && t.isIdempotent(req) {
+		return t.backoff(attempt), true
+	}
+
+	if !isRateLimited(resp) {
+		return 0, false
+	}
+
+	wait, ok := serverWait(resp)
+	if !ok {
+		return t.backoff(attempt), true
+	}
+
+	// don't block for a long time when the server asks to wait more than allowed
+	if wait > t.Config.MaxWait {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+// backoff returns the exponential backoff for the given attempt, with full jitter
+func (t *Transport) backoff(attempt int) time.Duration {
+	wait := t.Config.MinWait << attempt
+	if wait <= 0 || wait > t.Config.MaxWait {
+		wait = t.Config.MaxWait
+	}
+
+	if wait <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(wait)) + 1)
+}
+
+// isServerError checks if the response status represents a transient server error
+func isServerError(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRateLimited checks if the request was rejected because of a rate limit, without being processed
+func isRateLimited(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		// github uses 403 for both primary and secondary rate limits.
+		// See https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+		return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	default:
+		return false
+	}
+}
+
+// serverWait returns the time the server asked to wait before retrying, from the
+// "Retry-After" or the "X-RateLimit-Reset" headers
+func serverWait(resp *http.Response) (time.Duration, bool) {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			return nonNegative(time.Until(date)), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return nonNegative(time.Until(time.Unix(reset, 0))), true
+		}
+	}
+
+	return 0, false
+}
+
+// isIdempotent checks if the request can be sent again without side effects, even if the server processed it
+func (t *Transport) isIdempotent(req *http.Request) bool {
+	if req.Method == "" || req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+
+	return t.ReadOnly != nil && t.ReadOnly(req)
+}
+
+// notSent checks if the error was raised before the request was sent, while connecting to the server
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// canReplay checks if the request body can be sent again
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
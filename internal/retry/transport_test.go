@@ -0,0 +1,314 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/retry"
+)
+
+// newFakeServer creates a test server that replies with the given handlers, one per request.
+// The last handler is used for all the remaining requests.
+func newFakeServer(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&requests, 1)) - 1
+		if i >= len(handlers) {
+			i = len(handlers) - 1
+		}
+
+		handlers[i](w, r)
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func replyWithStatus(status int, headers map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for key, value := range headers {
+			w.Header().Set(key, value)
+		}
+
+		w.WriteHeader(status)
+	}
+}
+
+func newClient(config retry.Config) *http.Client {
+	return &http.Client{
+		Transport: retry.NewTransport(nil, config),
+	}
+}
+
+func testConfig() retry.Config {
+	return retry.Config{
+		MaxRetries: 3,
+		MinWait:    time.Millisecond,
+		MaxWait:    2 * time.Second,
+	}
+}
+
+func TestTransport_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries server errors", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t,
+			replyWithStatus(http.StatusBadGateway, nil),
+			replyWithStatus(http.StatusServiceUnavailable, nil),
+			replyWithStatus(http.StatusOK, nil),
+		)
+
+		resp, err := newClient(testConfig()).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+	})
+
+	t.Run("gives up after the maximum number of retries", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t, replyWithStatus(http.StatusInternalServerError, nil))
+
+		resp, err := newClient(testConfig()).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, int32(4), atomic.LoadInt32(requests))
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t, replyWithStatus(http.StatusForbidden, nil))
+
+		resp, err := newClient(testConfig()).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+
+	t.Run("does not retry when retries are disabled", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t, replyWithStatus(http.StatusServiceUnavailable, nil))
+
+		config := testConfig()
+		config.MaxRetries = 0
+		resp, err := newClient(config).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+
+	t.Run("honours the Retry-After header", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t,
+			replyWithStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": "1"}),
+			replyWithStatus(http.StatusOK, nil),
+		)
+
+		start := time.Now()
+		resp, err := newClient(testConfig()).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+
+	t.Run("does not retry when the server asks to wait longer than the maximum wait", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t, replyWithStatus(http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"}))
+
+		resp, err := newClient(testConfig()).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+
+	t.Run("retries github rate limit errors until the limit resets", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t,
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix(), 10))
+				w.WriteHeader(http.StatusForbidden)
+			},
+			replyWithStatus(http.StatusOK, nil),
+		)
+
+		resp, err := newClient(testConfig()).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+	})
+
+	t.Run("retries github secondary rate limit errors", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t,
+			replyWithStatus(http.StatusForbidden, map[string]string{"Retry-After": "0"}),
+			replyWithStatus(http.StatusOK, nil),
+		)
+
+		resp, err := newClient(testConfig()).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+	})
+
+	t.Run("sends the request body on every attempt", func(t *testing.T) {
+		t.Parallel()
+
+		bodies := make(chan string, 2)
+		readBody := func(status int) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies <- string(body)
+				w.WriteHeader(status)
+			}
+		}
+
+		server, _ := newFakeServer(t, readBody(http.StatusTooManyRequests), readBody(http.StatusOK))
+
+		resp, err := newClient(testConfig()).Post(server.URL, "application/json", strings.NewReader(`{"name":"test"}`))
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `{"name":"test"}`, <-bodies)
+		assert.Equal(t, `{"name":"test"}`, <-bodies)
+	})
+
+	t.Run("does not retry server errors of requests that are not idempotent", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t, replyWithStatus(http.StatusBadGateway, nil), replyWithStatus(http.StatusOK, nil))
+
+		resp, err := newClient(testConfig()).Post(server.URL, "application/json", strings.NewReader(`{"name":"test"}`))
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	})
+
+	t.Run("retries server errors of the requests marked as read only", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t, replyWithStatus(http.StatusServiceUnavailable, nil), replyWithStatus(http.StatusOK, nil))
+
+		transport := retry.NewTransport(nil, testConfig())
+		transport.ReadOnly = func(req *http.Request) bool {
+			return strings.HasSuffix(req.URL.Path, "/query")
+		}
+
+		resp, err := (&http.Client{Transport: transport}).Post(server.URL+"/v1/databases/123/query", "application/json", strings.NewReader(`{}`))
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+	})
+
+	t.Run("retries the network errors of requests that are not idempotent only when they were not sent", func(t *testing.T) {
+		t.Parallel()
+
+		tests := map[string]struct {
+			err      error
+			attempts int32
+		}{
+			"connection refused": {err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, attempts: 4},
+			"connection reset":   {err: &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}, attempts: 1},
+			"unexpected eof":     {err: io.ErrUnexpectedEOF, attempts: 1},
+		}
+
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				var attempts int32
+				client := &http.Client{
+					Transport: retry.NewTransport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+						atomic.AddInt32(&attempts, 1)
+						return nil, tc.err
+					}), testConfig()),
+				}
+
+				_, err := client.Post("http://example.com", "application/json", strings.NewReader(`{"name":"test"}`))
+
+				require.Error(t, err)
+				assert.Equal(t, tc.attempts, atomic.LoadInt32(&attempts))
+			})
+		}
+	})
+
+	t.Run("waits for the limiter before each retry", func(t *testing.T) {
+		t.Parallel()
+
+		server, requests := newFakeServer(t,
+			replyWithStatus(http.StatusServiceUnavailable, nil),
+			replyWithStatus(http.StatusServiceUnavailable, nil),
+			replyWithStatus(http.StatusOK, nil),
+		)
+
+		limiter := &countingLimiter{}
+		transport := retry.NewTransport(nil, testConfig())
+		transport.Limiter = limiter
+
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&limiter.waits))
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// countingLimiter is a limiter that never waits, and counts how many times it was called
+type countingLimiter struct {
+	waits int32
+}
+
+func (l *countingLimiter) Wait(context.Context) error {
+	atomic.AddInt32(&l.waits, 1)
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package state provides a local store that persists information between sync runs.
+package state
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/etag"
+)
+
+var (
+	metaBucket  = []byte("meta")
+	reposBucket = []byte("repos")
+	etagsBucket = []byte("etags")
+	// etagTimesBucket holds the time each cached response was saved, by the key of the response
+	etagTimesBucket = []byte("etag-times")
+)
+
+// cursorKeyPrefix is the prefix of the keys of the cursors, that are followed by their scope
+const cursorKeyPrefix = "cursor/"
+
+// MaxETags is the maximum number of cached responses. When it's exceeded, the responses saved the longest time ago
+// are removed, so that the state file doesn't keep growing.
+const MaxETags = 500
+
+// Store is a local state store, backed by a bbolt database file
+type Store struct {
+	db *bolt.DB
+}
+
+// Cursor records when the last successful syncs happened
+type Cursor struct {
+	// LastSyncAt is the start time of the last successful sync, either incremental or full
+	LastSyncAt time.Time `json:"last_sync_at"`
+	// LastFullSyncAt is the start time of the last successful full sync
+	LastFullSyncAt time.Time `json:"last_full_sync_at"`
+}
+
+// RepoRecord records how a starred repo was synced to notion
+type RepoRecord struct {
+	RepoID int64  `json:"repo_id"`
+	PageID string `json:"page_id"`
+	// Name is the name of the repo when it was last synced. It allows to detect renamed repos.
+	Name string `json:"name"`
+	// Unstarred is set when the repo was unstarred and its page archived
+	Unstarred bool `json:"unstarred"`
+	// Archived is set when the repo was archived on github. It allows to detect the repos that are archived later.
+	Archived bool `json:"archived"`
+	// CopyID is the copy of the page in the archive database, when the page was moved there after the repo was
+	// unstarred. It allows to remove the copy when the repo is starred again.
+	CopyID   string    `json:"copy_id,omitempty"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// Open opens the state store at the given path, creating it if it doesn't exist
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating state directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening state file: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{metaBucket, reposBucket, etagsBucket, etagTimesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error initializing state file: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the state store
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Cursor returns the cursor of the last successful sync of the given scope, which identifies what was synced.
+// A zero cursor is returned if no sync of that scope happened yet.
+func (s *Store) Cursor(scope string) (Cursor, error) {
+	var cursor Cursor
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(cursorKey(scope))
+		if data == nil {
+			return nil
+		}
+
+		return json.Unmarshal(data, &cursor)
+	})
+
+	return cursor, err
+}
+
+// SaveCursor persists the cursor of the last successful sync of the given scope
+func (s *Store) SaveCursor(scope string, cursor Cursor) error {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(cursorKey(scope), data)
+	})
+}
+
+// Repos returns the records of all the synced repos, indexed by repo ID
+func (s *Store) Repos() (map[int64]RepoRecord, error) {
+	records := make(map[int64]RepoRecord)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(reposBucket).ForEach(func(_, data []byte) error {
+			var record RepoRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+
+			records[record.RepoID] = record
+
+			return nil
+		})
+	})
+
+	return records, err
+}
+
+// SaveRepo creates or replaces the record of a synced repo
+func (s *Store) SaveRepo(record RepoRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(reposBucket).Put(repoKey(record.RepoID), data)
+	})
+}
+
+// ETag returns the cached response stored for the given key, if any
+func (s *Store) ETag(key string) (etag.Entry, bool, error) {
+	var entry etag.Entry
+	found := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(etagsBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+
+		found = true
+
+		return json.Unmarshal(data, &entry)
+	})
+
+	return entry, found, err
+}
+
+// SaveETag stores a cached response for the given key, replacing the previous one.
+// The responses saved the longest time ago are removed when there are more than MaxETags.
+func (s *Store) SaveETag(key string, entry etag.Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(etagsBucket).Put([]byte(key), data); err != nil {
+			return err
+		}
+
+		savedAt := strconv.FormatInt(time.Now().UnixNano(), 10)
+		if err := tx.Bucket(etagTimesBucket).Put([]byte(key), []byte(savedAt)); err != nil {
+			return err
+		}
+
+		return evictETags(tx)
+	})
+}
+
+// evictETags removes the cached responses saved the longest time ago, until there are at most MaxETags.
+// The responses cached before their time was recorded are removed first.
+func evictETags(tx *bolt.Tx) error {
+	type savedETag struct {
+		key     []byte
+		savedAt int64
+	}
+
+	etags := tx.Bucket(etagsBucket)
+	times := tx.Bucket(etagTimesBucket)
+	saved := make([]savedETag, 0, MaxETags+1)
+	err := etags.ForEach(func(key, _ []byte) error {
+		// a missing or invalid time is parsed as zero
+		savedAt, _ := strconv.ParseInt(string(times.Get(key)), 10, 64)
+		saved = append(saved, savedETag{key: slices.Clone(key), savedAt: savedAt})
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(saved) <= MaxETags {
+		return nil
+	}
+
+	slices.SortFunc(saved, func(a, b savedETag) int {
+		return cmp.Compare(a.savedAt, b.savedAt)
+	})
+
+	for _, entry := range saved[:len(saved)-MaxETags] {
+		if err := etags.Delete(entry.key); err != nil {
+			return err
+		}
+
+		if err := times.Delete(entry.key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func cursorKey(scope string) []byte {
+	return []byte(cursorKeyPrefix + scope)
+}
+
+func repoKey(repoID int64) []byte {
+	return []byte(strconv.FormatInt(repoID, 10))
+}
@@ -0,0 +1,159 @@
+package state_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/etag"
+	"github.com/brpaz/github-stars-notion-sync/internal/state"
+)
+
+func openStore(t *testing.T, path string) *state.Store {
+	t.Helper()
+
+	store, err := state.Open(path)
+	require.NoError(t, err)
+
+	return store
+}
+
+func TestStore_Cursor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns a zero cursor when nothing was synced", func(t *testing.T) {
+		t.Parallel()
+
+		store := openStore(t, filepath.Join(t.TempDir(), "state.db"))
+		defer store.Close()
+
+		cursor, err := store.Cursor("database/")
+
+		require.NoError(t, err)
+		assert.True(t, cursor.LastSyncAt.IsZero())
+		assert.True(t, cursor.LastFullSyncAt.IsZero())
+	})
+
+	t.Run("persists the cursor between runs", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "nested", "state.db")
+		expected := state.Cursor{
+			LastSyncAt:     time.Date(2024, 1, 7, 10, 0, 0, 0, time.UTC),
+			LastFullSyncAt: time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC),
+		}
+
+		store := openStore(t, path)
+		require.NoError(t, store.SaveCursor("database/", expected))
+		require.NoError(t, store.Close())
+
+		store = openStore(t, path)
+		defer store.Close()
+
+		cursor, err := store.Cursor("database/")
+
+		require.NoError(t, err)
+		assert.True(t, expected.LastSyncAt.Equal(cursor.LastSyncAt))
+		assert.True(t, expected.LastFullSyncAt.Equal(cursor.LastFullSyncAt))
+	})
+
+	t.Run("keeps a cursor per scope", func(t *testing.T) {
+		t.Parallel()
+
+		store := openStore(t, filepath.Join(t.TempDir(), "state.db"))
+		defer store.Close()
+
+		require.NoError(t, store.SaveCursor("database/", state.Cursor{LastSyncAt: time.Date(2024, 1, 7, 10, 0, 0, 0, time.UTC)}))
+
+		cursor, err := store.Cursor("other-database/")
+
+		require.NoError(t, err)
+		assert.True(t, cursor.LastSyncAt.IsZero())
+	})
+}
+
+func TestStore_Repos(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns no records when nothing was synced", func(t *testing.T) {
+		t.Parallel()
+
+		store := openStore(t, filepath.Join(t.TempDir(), "state.db"))
+		defer store.Close()
+
+		records, err := store.Repos()
+
+		require.NoError(t, err)
+		assert.Empty(t, records)
+	})
+
+	t.Run("saves and replaces repo records", func(t *testing.T) {
+		t.Parallel()
+
+		store := openStore(t, filepath.Join(t.TempDir(), "state.db"))
+		defer store.Close()
+
+		require.NoError(t, store.SaveRepo(state.RepoRecord{RepoID: 1, PageID: "page-1", Name: "repo-1"}))
+		require.NoError(t, store.SaveRepo(state.RepoRecord{RepoID: 2, PageID: "page-2", Name: "repo-2"}))
+		require.NoError(t, store.SaveRepo(state.RepoRecord{RepoID: 1, PageID: "page-1", Name: "renamed", Unstarred: true}))
+
+		records, err := store.Repos()
+
+		require.NoError(t, err)
+		assert.Len(t, records, 2)
+		assert.Equal(t, "renamed", records[1].Name)
+		assert.True(t, records[1].Unstarred)
+		assert.Equal(t, "page-2", records[2].PageID)
+	})
+}
+
+func TestStore_ETag(t *testing.T) {
+	t.Parallel()
+
+	store := openStore(t, filepath.Join(t.TempDir(), "state.db"))
+	defer store.Close()
+
+	_, found, err := store.ETag("https://api.github.com/user/starred")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	expected := etag.Entry{
+		ETag:   `W/"abc"`,
+		Header: http.Header{"Link": []string{`<https://api.github.com/user/starred?page=2>; rel="next"`}},
+		Body:   []byte(`[{"id":1}]`),
+	}
+	require.NoError(t, store.SaveETag("https://api.github.com/user/starred", expected))
+
+	entry, found, err := store.ETag("https://api.github.com/user/starred")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, expected, entry)
+}
+
+func TestStore_ETag_Eviction(t *testing.T) {
+	t.Parallel()
+
+	store := openStore(t, filepath.Join(t.TempDir(), "state.db"))
+	defer store.Close()
+
+	entry := etag.Entry{ETag: `W/"abc"`, Body: []byte(`[{"id":1}]`)}
+	for page := 1; page <= state.MaxETags+1; page++ {
+		require.NoError(t, store.SaveETag(fmt.Sprintf("https://api.github.com/user/starred?page=%d", page), entry))
+	}
+
+	// the first response saved is removed to make room for the last one
+	_, found, err := store.ETag("https://api.github.com/user/starred?page=1")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	for _, page := range []int{2, state.MaxETags + 1} {
+		_, found, err := store.ETag(fmt.Sprintf("https://api.github.com/user/starred?page=%d", page))
+		require.NoError(t, err)
+		assert.True(t, found, page)
+	}
+}
@@ -0,0 +1,201 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jomei/notionapi"
+)
+
+// DefaultDatabaseTitle is the title of the notion databases created by CreateDatabase, when no title is given
+const DefaultDatabaseTitle = "GitHub Stars"
+
+var ErrParentPageIDRequired = errors.New("parent page id is required")
+
+// DatabaseOptions configures the notion database created by CreateDatabase
+type DatabaseOptions struct {
+	// ParentPageID is the id of the notion page where the database is created
+	ParentPageID string
+	Title        string
+	Mapping      PropertyMapping
+	// StarLists adds the property that stores the star lists. When ListsDatabaseID is set, it is a relation
+	// to the pages of that database, instead of a multi-select.
+	StarLists       bool
+	ListsDatabaseID string
+	// StarredBy adds the property that stores the accounts that starred each repo, used when several accounts are synced
+	StarredBy bool
+	// UnstarPolicy adds the properties required by the policy applied to the pages of unstarred repos
+	UnstarPolicy UnstarPolicy
+}
+
+// databaseFeatures holds the optional features that require additional properties in the notion database
+type databaseFeatures struct {
+	starLists bool
+	// listsRelation stores the star lists as a relation instead of a multi-select
+	listsRelation bool
+	starredBy     bool
+	markUnstarred bool
+}
+
+// databaseProperties returns the properties that the notion database must have, depending on the enabled features
+func databaseProperties(features databaseFeatures) []RequiredProperty {
+	properties := slices.Clone(requiredProperties)
+
+	if features.starLists {
+		listsProperty := RequiredProperty{
+			Field:        FieldLists,
+			PropertyType: notionapi.PropertyTypeMultiSelect,
+		}
+
+		if features.listsRelation {
+			listsProperty.PropertyType = notionapi.PropertyTypeRelation
+		}
+
+		properties = append(properties, listsProperty)
+	}
+
+	if features.starredBy {
+		properties = append(properties, RequiredProperty{
+			Field:        FieldStarredBy,
+			PropertyType: notionapi.PropertyTypeMultiSelect,
+		})
+	}
+
+	if features.markUnstarred {
+		properties = append(properties,
+			RequiredProperty{
+				Field:        FieldUnstarred,
+				PropertyType: notionapi.PropertyTypeCheckbox,
+			},
+			RequiredProperty{
+				Field:        FieldUnstarredAt,
+				PropertyType: notionapi.PropertyTypeDate,
+			},
+		)
+	}
+
+	return properties
+}
+
+// CreateDatabase creates a notion database with all the properties required to sync the stars, and returns its id
+func CreateDatabase(ctx context.Context, notionClient *notionapi.Client, options DatabaseOptions) (string, error) {
+	if notionClient == nil {
+		return "", ErrNilNotionClient
+	}
+
+	request, err := buildDatabaseCreateRequest(options)
+	if err != nil {
+		return "", err
+	}
+
+	database, err := notionClient.Database.Create(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("error creating notion database: %w", err)
+	}
+
+	return database.ID.String(), nil
+}
+
+// buildDatabaseCreateRequest builds the request that creates a notion database with the required properties
+func buildDatabaseCreateRequest(options DatabaseOptions) (*notionapi.DatabaseCreateRequest, error) {
+	if options.ParentPageID == "" {
+		return nil, ErrParentPageIDRequired
+	}
+
+	mapping := options.Mapping
+	if mapping == nil {
+		mapping = DefaultPropertyMapping()
+	}
+
+	if err := mapping.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid property mapping: %w", err)
+	}
+
+	title := options.Title
+	if title == "" {
+		title = DefaultDatabaseTitle
+	}
+
+	properties := make(notionapi.PropertyConfigs)
+	features := databaseFeatures{
+		starLists:     options.StarLists,
+		listsRelation: options.ListsDatabaseID != "",
+		starredBy:     options.StarredBy,
+		markUnstarred: options.UnstarPolicy == UnstarMark,
+	}
+
+	// the optional properties are added too, so that new databases store all the information of the repos
+	for _, property := range append(databaseProperties(features), optionalProperties...) {
+		config, err := buildPropertyConfig(property.PropertyType, notionapi.DatabaseID(options.ListsDatabaseID))
+		if err != nil {
+			return nil, err
+		}
+
+		properties[mapping.Name(property.Field)] = config
+	}
+
+	return &notionapi.DatabaseCreateRequest{
+		Parent: notionapi.Parent{
+			Type:   notionapi.ParentTypePageID,
+			PageID: notionapi.PageID(options.ParentPageID),
+		},
+		Title: []notionapi.RichText{
+			{
+				Type: notionapi.ObjectTypeText,
+				Text: &notionapi.Text{
+					Content: title,
+				},
+			},
+		},
+		Properties: properties,
+	}, nil
+}
+
+// buildPropertyConfig builds the schema of a database property of the given type.
+// Select options are created by notion as the pages are synced, so they start empty.
+func buildPropertyConfig(propertyType notionapi.PropertyType, listsDatabaseID notionapi.DatabaseID) (notionapi.PropertyConfig, error) {
+	configType := notionapi.PropertyConfigType(propertyType)
+
+	switch propertyType {
+	case notionapi.PropertyTypeTitle:
+		return &notionapi.TitlePropertyConfig{Type: configType}, nil
+	case notionapi.PropertyTypeCreatedTime:
+		return &notionapi.CreatedTimePropertyConfig{Type: configType}, nil
+	case notionapi.PropertyTypeRichText:
+		return &notionapi.RichTextPropertyConfig{Type: configType}, nil
+	case notionapi.PropertyTypeURL:
+		return &notionapi.URLPropertyConfig{Type: configType}, nil
+	case notionapi.PropertyTypeNumber:
+		return &notionapi.NumberPropertyConfig{
+			Type:   configType,
+			Number: notionapi.NumberFormat{Format: notionapi.FormatNumber},
+		}, nil
+	case notionapi.PropertyTypeSelect:
+		return &notionapi.SelectPropertyConfig{
+			Type:   configType,
+			Select: notionapi.Select{Options: []notionapi.Option{}},
+		}, nil
+	case notionapi.PropertyTypeMultiSelect:
+		return &notionapi.MultiSelectPropertyConfig{
+			Type:        configType,
+			MultiSelect: notionapi.Select{Options: []notionapi.Option{}},
+		}, nil
+	case notionapi.PropertyTypeCheckbox:
+		return &notionapi.CheckboxPropertyConfig{Type: configType}, nil
+	case notionapi.PropertyTypeDate:
+		return &notionapi.DatePropertyConfig{Type: configType}, nil
+	case notionapi.PropertyTypeRelation:
+		return &notionapi.RelationPropertyConfig{
+			Type: configType,
+			Relation: notionapi.RelationConfig{
+				DatabaseID:     listsDatabaseID,
+				Type:           notionapi.RelationSingleProperty,
+				SingleProperty: &notionapi.SingleProperty{},
+			},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported property type %s", propertyType)
+	}
+}
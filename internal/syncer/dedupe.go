@@ -0,0 +1,406 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/jomei/notionapi"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/log"
+	"github.com/brpaz/github-stars-notion-sync/internal/markdown"
+)
+
+// KeepStrategy chooses the page that is kept among the duplicate pages of a repo
+type KeepStrategy string
+
+const (
+	// KeepOldest keeps the page that was created first
+	KeepOldest KeepStrategy = "oldest"
+	// KeepMostContent keeps the page with the most blocks in its body, which is usually the one with the most notes.
+	// Ties are broken by keeping the oldest page.
+	KeepMostContent KeepStrategy = "most-content"
+)
+
+var ErrInvalidKeepStrategy = errors.New("keep strategy must be one of: oldest, most-content")
+
+// Validate checks that the strategy is a known one
+func (k KeepStrategy) Validate() error {
+	switch k {
+	case KeepOldest, KeepMostContent:
+		return nil
+	default:
+		return ErrInvalidKeepStrategy
+	}
+}
+
+// DuplicatePages holds the pages of the notion database that hold the same repo
+type DuplicatePages struct {
+	RepoID  int64    `json:"repository_id"`
+	Name    string   `json:"name"`
+	PageIDs []string `json:"page_ids"`
+}
+
+// DedupePlan holds the changes that merge the duplicate pages of the notion database
+type DedupePlan struct {
+	DatabaseID string      `json:"database_id"`
+	Duplicates []Duplicate `json:"duplicates"`
+}
+
+// Duplicate describes how the duplicate pages of a repo are merged
+type Duplicate struct {
+	RepoID int64  `json:"repository_id"`
+	Name   string `json:"name"`
+	// KeepPageID is the page that is kept, where the properties of the other pages are merged
+	KeepPageID string `json:"keep_page_id"`
+	// ArchivePageIDs are the duplicate pages that are archived once merged
+	ArchivePageIDs []string `json:"archive_page_ids"`
+	// ProtectedPageIDs are the duplicate pages that are left out of the merge, because they are protected
+	ProtectedPageIDs []string `json:"protected_page_ids,omitempty"`
+	// MergedProperties holds the names of the properties of the kept page that are filled with the values of the
+	// other pages
+	MergedProperties []string `json:"merged_properties,omitempty"`
+
+	request *notionapi.PageUpdateRequest
+}
+
+// duplicatePages returns the repos that have several pages in the notion database
+func duplicatePages(pages *databasePages) []DuplicatePages {
+	groups := pages.Duplicates()
+
+	duplicates := make([]DuplicatePages, 0, len(groups))
+	for _, group := range groups {
+		duplicate := DuplicatePages{
+			RepoID:  group[0].GitHubID,
+			Name:    group[0].Title,
+			PageIDs: make([]string, len(group)),
+		}
+
+		for i, page := range group {
+			duplicate.PageIDs[i] = page.ID
+		}
+
+		duplicates = append(duplicates, duplicate)
+	}
+
+	return duplicates
+}
+
+// PlanDedupe finds the repos that have several pages in the notion database, and computes how they are merged,
+// without making any change to the notion database. The kept page of each repo is chosen with the given strategy.
+// Protected pages are left out of the merges: they are never written, merged into another page or archived, and the
+// repos with less than two pages that aren't protected are not merged at all.
+func (s *Syncer) PlanDedupe(ctx context.Context, notionDatabaseID string, strategy KeepStrategy) (*DedupePlan, error) {
+	if err := strategy.Validate(); err != nil {
+		return nil, err
+	}
+
+	databaseID := notionapi.DatabaseID(notionDatabaseID)
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	notionDatabase, err := s.notion.Database.Get(ctx, databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting notion database: %w", err)
+	}
+
+	if err := s.validateDatabaseFields(notionDatabase); err != nil {
+		return nil, fmt.Errorf("error validating notion database: %w", err)
+	}
+
+	log.Info(ctx, "fetching pages from notion database. Depending on the size of the database, this might take a while.")
+	results, err := s.queryNotionDatabase(ctx, databaseID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting notion pages: %w", err)
+	}
+
+	pages := newDatabasePages()
+	rawPages := make(map[string]*notionapi.Page, len(results))
+	for i := range results {
+		// pages that can't be matched with a repo are not duplicates of any other page
+		page, err := parseNotionPage(results[i], s.mapping)
+		if err != nil {
+			continue
+		}
+
+		pages.Add(page)
+		rawPages[page.ID] = &results[i]
+	}
+
+	plan := &DedupePlan{
+		DatabaseID: databaseID.String(),
+		Duplicates: make([]Duplicate, 0),
+	}
+
+	for _, group := range pages.Duplicates() {
+		duplicate, ok, err := s.planDuplicate(ctx, group, rawPages, strategy)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the duplicate pages of %s: %w", group[0].Title, err)
+		}
+
+		if !ok {
+			log.Warn(ctx, "duplicate pages of repository are protected, merge them by hand", log.String("repo", group[0].Title))
+			continue
+		}
+
+		plan.Duplicates = append(plan.Duplicates, duplicate)
+	}
+
+	log.Info(ctx, fmt.Sprintf("found %d repositories with duplicate pages", len(plan.Duplicates)))
+
+	return plan, nil
+}
+
+// planDuplicate chooses the page kept among the duplicate pages of a repo, and the properties merged into it.
+// It returns false when less than two of the pages aren't protected, since there is nothing to merge.
+func (s *Syncer) planDuplicate(ctx context.Context, group []*notionPage, rawPages map[string]*notionapi.Page, strategy KeepStrategy) (Duplicate, bool, error) {
+	// protected pages are never written, so they can't be kept, and their properties are not merged either
+	candidates := make([]*notionapi.Page, 0, len(group))
+	for _, page := range group {
+		if !page.Protected {
+			candidates = append(candidates, rawPages[page.ID])
+		}
+	}
+
+	if len(candidates) < 2 {
+		return Duplicate{}, false, nil
+	}
+
+	kept, err := s.choosePage(ctx, candidates, strategy)
+	if err != nil {
+		return Duplicate{}, false, err
+	}
+
+	duplicate := Duplicate{
+		RepoID:         group[0].GitHubID,
+		Name:           group[0].Title,
+		KeepPageID:     kept.ID.String(),
+		ArchivePageIDs: make([]string, 0, len(group)-1),
+	}
+
+	others := make([]*notionapi.Page, 0, len(group)-1)
+	for _, page := range group {
+		if page.ID == duplicate.KeepPageID {
+			continue
+		}
+
+		if page.Protected {
+			duplicate.ProtectedPageIDs = append(duplicate.ProtectedPageIDs, page.ID)
+			continue
+		}
+
+		others = append(others, rawPages[page.ID])
+		duplicate.ArchivePageIDs = append(duplicate.ArchivePageIDs, page.ID)
+	}
+
+	if properties := s.mergeUserProperties(kept, others); len(properties) > 0 {
+		duplicate.request = &notionapi.PageUpdateRequest{Properties: properties}
+		duplicate.MergedProperties = propertyNames(properties)
+	}
+
+	return duplicate, true, nil
+}
+
+// choosePage returns the page kept among the given duplicate pages, according to the strategy
+func (s *Syncer) choosePage(ctx context.Context, pages []*notionapi.Page, strategy KeepStrategy) (*notionapi.Page, error) {
+	sorted := slices.Clone(pages)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedTime.Before(sorted[j].CreatedTime)
+	})
+
+	if strategy != KeepMostContent {
+		return sorted[0], nil
+	}
+
+	kept, keptBlocks := sorted[0], -1
+	for _, page := range sorted {
+		blocks, err := s.countPageBlocks(ctx, page.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if blocks > keptBlocks {
+			kept, keptBlocks = page, blocks
+		}
+	}
+
+	return kept, nil
+}
+
+// countPageBlocks returns the number of top level blocks in the body of the page
+func (s *Syncer) countPageBlocks(ctx context.Context, pageID notionapi.ObjectID) (int, error) {
+	count := 0
+
+	cursor := notionapi.Cursor("")
+	for {
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return 0, err
+		}
+
+		resp, err := s.notion.Block.GetChildren(ctx, notionapi.BlockID(pageID), &notionapi.Pagination{
+			StartCursor: cursor,
+			PageSize:    markdown.MaxBlocksPerRequest,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		count += len(resp.Results)
+
+		if !resp.HasMore {
+			return count, nil
+		}
+
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+}
+
+// mergeUserProperties returns the properties of the kept page that are updated with the values of the other pages.
+// Only the properties that are not written by the sync are merged: the empty ones are filled with the first value
+// found in the other pages, and multi-selects and relations get the values of all the pages.
+func (s *Syncer) mergeUserProperties(kept *notionapi.Page, others []*notionapi.Page) notionapi.Properties {
+	syncedProperties := make(map[string]bool, len(defaultPropertyNames))
+	for field := range defaultPropertyNames {
+		if field != FieldProtected {
+			syncedProperties[s.mapping.Name(field)] = true
+		}
+	}
+
+	merged := make(notionapi.Properties)
+	for name, property := range kept.Properties {
+		if syncedProperties[name] || !writablePropertyTypes[property.GetType()] {
+			continue
+		}
+
+		value := property
+		for _, other := range others {
+			otherProperty, ok := other.Properties[name]
+			if !ok || otherProperty.GetType() != property.GetType() {
+				continue
+			}
+
+			value = mergeProperty(value, otherProperty)
+		}
+
+		if value != property {
+			merged[name] = value
+		}
+	}
+
+	return merged
+}
+
+// mergeProperty returns the value of a property merged with the value of the same property in another page.
+// The original property is returned when the merge doesn't change it.
+func mergeProperty(property, other notionapi.Property) notionapi.Property {
+	switch p := property.(type) {
+	case *notionapi.MultiSelectProperty:
+		names := make([]string, 0, len(p.MultiSelect))
+		for _, option := range p.MultiSelect {
+			names = append(names, option.Name)
+		}
+
+		merged := slices.Clone(names)
+		for _, option := range other.(*notionapi.MultiSelectProperty).MultiSelect {
+			if !slices.Contains(merged, option.Name) {
+				merged = append(merged, option.Name)
+			}
+		}
+
+		if len(merged) == len(names) {
+			return property
+		}
+
+		return buildMultiSelectProperty(merged)
+	case *notionapi.RelationProperty:
+		merged := slices.Clone(p.Relation)
+		for _, relation := range other.(*notionapi.RelationProperty).Relation {
+			if !slices.Contains(merged, relation) {
+				merged = append(merged, relation)
+			}
+		}
+
+		if len(merged) == len(p.Relation) {
+			return property
+		}
+
+		return &notionapi.RelationProperty{Relation: merged}
+	}
+
+	if isEmptyProperty(property) && !isEmptyProperty(other) {
+		return copiedProperty{other}
+	}
+
+	return property
+}
+
+// isEmptyProperty tells if the property of a page has no value
+func isEmptyProperty(property notionapi.Property) bool {
+	switch p := property.(type) {
+	case *notionapi.RichTextProperty:
+		return len(p.RichText) == 0
+	case *notionapi.NumberProperty:
+		return p.Number == 0
+	case *notionapi.SelectProperty:
+		return p.Select.Name == ""
+	case *notionapi.StatusProperty:
+		return p.Status.Name == ""
+	case *notionapi.DateProperty:
+		return p.Date == nil
+	case *notionapi.PeopleProperty:
+		return len(p.People) == 0
+	case *notionapi.CheckboxProperty:
+		return !p.Checkbox
+	case *notionapi.URLProperty:
+		return p.URL == ""
+	case *notionapi.EmailProperty:
+		return p.Email == ""
+	case *notionapi.PhoneNumberProperty:
+		return p.PhoneNumber == ""
+	default:
+		return false
+	}
+}
+
+// Dedupe merges the properties of the duplicate pages of each repo into the kept page, and archives the others.
+// The duplicate pages of a repo are only archived once their properties are merged.
+func (s *Syncer) Dedupe(ctx context.Context, plan *DedupePlan) (*SyncResult, error) {
+	result := &SyncResult{}
+
+	for _, duplicate := range plan.Duplicates {
+		if duplicate.request != nil {
+			if err := s.notionLimiter.Wait(ctx); err != nil {
+				return result, err
+			}
+
+			if _, err := s.notion.Page.Update(ctx, notionapi.PageID(duplicate.KeepPageID), duplicate.request); err != nil {
+				log.Error(ctx, "error merging duplicate pages", log.String("repo", duplicate.Name), log.String("error", err.Error()))
+				result.recordFailure(&Change{Action: ActionUpdate, Name: duplicate.Name}, err)
+				continue
+			}
+
+			result.recordSuccess(ActionUpdate)
+		}
+
+		for _, pageID := range duplicate.ArchivePageIDs {
+			if err := s.deleteNotionPage(ctx, notionapi.PageID(pageID)); err != nil {
+				log.Error(ctx, "error archiving duplicate page", log.String("repo", duplicate.Name), log.String("page", pageID), log.String("error", err.Error()))
+				result.recordFailure(&Change{Action: ActionArchive, Name: duplicate.Name}, err)
+				continue
+			}
+
+			result.recordSuccess(ActionArchive)
+		}
+
+		log.Info(ctx, "duplicate pages merged", log.String("repo", duplicate.Name), log.String("page", duplicate.KeepPageID))
+	}
+
+	if err := result.err(); err != nil {
+		return result, fmt.Errorf("error merging duplicate pages: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,269 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jomei/notionapi"
+)
+
+// unauthenticatedGitHubRateLimit is the number of requests per hour allowed by github without a token
+const unauthenticatedGitHubRateLimit = 60
+
+// CheckStatus is the outcome of a doctor check
+type CheckStatus string
+
+const (
+	CheckOK      CheckStatus = "ok"
+	CheckWarning CheckStatus = "warning"
+	CheckFailed  CheckStatus = "failed"
+)
+
+// Check is the result of a single doctor check
+type Check struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message"`
+}
+
+// SchemaProblem is a property of the notion database that doesn't match the schema required by the syncer
+type SchemaProblem struct {
+	Field        Field                  `json:"field"`
+	Property     string                 `json:"property"`
+	ExpectedType notionapi.PropertyType `json:"expected_type"`
+	// ActualType is empty when the property is missing
+	ActualType notionapi.PropertyType `json:"actual_type,omitempty"`
+}
+
+// Missing tells if the property doesn't exist in the database
+func (p SchemaProblem) Missing() bool {
+	return p.ActualType == ""
+}
+
+// Fixable tells if the problem can be fixed by updating the database schema.
+// A database always has a single title property, so it can't be added or converted.
+func (p SchemaProblem) Fixable() bool {
+	return p.ExpectedType != notionapi.PropertyTypeTitle
+}
+
+func (p SchemaProblem) String() string {
+	if p.Missing() {
+		return fmt.Sprintf("notion database is missing required property %s", p.Property)
+	}
+
+	return fmt.Sprintf("notion database property %s is of type %s, but should be %s", p.Property, p.ActualType, p.ExpectedType)
+}
+
+// Diagnosis holds the results of all the doctor checks
+type Diagnosis struct {
+	Checks         []Check         `json:"checks"`
+	SchemaProblems []SchemaProblem `json:"schema_problems"`
+}
+
+// Healthy tells if all the checks passed. Warnings don't prevent the sync from working.
+func (d *Diagnosis) Healthy() bool {
+	for _, check := range d.Checks {
+		if check.Status == CheckFailed {
+			return false
+		}
+	}
+
+	return len(d.SchemaProblems) == 0
+}
+
+func (d *Diagnosis) add(name string, status CheckStatus, message string) {
+	d.Checks = append(d.Checks, Check{Name: name, Status: status, Message: message})
+}
+
+// Diagnose checks everything the sync depends on, and reports all the problems at once instead of stopping at
+// the first one: the notion token, the access of the integration to the database, the database schema and
+// the github accounts.
+func (s *Syncer) Diagnose(ctx context.Context, notionDatabaseID string) *Diagnosis {
+	diagnosis := &Diagnosis{
+		Checks:         make([]Check, 0),
+		SchemaProblems: make([]SchemaProblem, 0),
+	}
+
+	s.diagnoseNotion(ctx, diagnosis, notionapi.DatabaseID(notionDatabaseID))
+	s.diagnoseGitHub(ctx, diagnosis)
+
+	return diagnosis
+}
+
+func (s *Syncer) diagnoseNotion(ctx context.Context, diagnosis *Diagnosis, databaseID notionapi.DatabaseID) {
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		diagnosis.add("notion token", CheckFailed, err.Error())
+		return
+	}
+
+	bot, err := s.notion.User.Me(ctx)
+	if err != nil {
+		diagnosis.add("notion token", CheckFailed, err.Error())
+		return
+	}
+
+	diagnosis.add("notion token", CheckOK, fmt.Sprintf("authenticated as %s", bot.Name))
+
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		diagnosis.add("notion database", CheckFailed, err.Error())
+		return
+	}
+
+	database, err := s.notion.Database.Get(ctx, databaseID)
+	if err != nil {
+		diagnosis.add("notion database", CheckFailed, err.Error())
+		return
+	}
+
+	diagnosis.add("notion database", CheckOK, fmt.Sprintf("the integration has access to %s", plainText(database.Title)))
+
+	diagnosis.SchemaProblems = s.schemaProblems(database)
+	if len(diagnosis.SchemaProblems) == 0 {
+		diagnosis.add("notion database schema", CheckOK, "all the required properties exist")
+	} else {
+		diagnosis.add("notion database schema", CheckFailed, fmt.Sprintf("%d properties don't match the required schema", len(diagnosis.SchemaProblems)))
+	}
+
+	if s.listsDatabaseID != "" {
+		if _, err := s.loadListsDatabase(ctx); err != nil {
+			diagnosis.add("notion lists database", CheckFailed, err.Error())
+		} else {
+			diagnosis.add("notion lists database", CheckOK, "the integration has access to the lists database")
+		}
+	}
+}
+
+func (s *Syncer) diagnoseGitHub(ctx context.Context, diagnosis *Diagnosis) {
+	accounts := append([]gitHubAccount{{client: s.github, user: s.githubUser}}, s.accounts...)
+
+	for i, account := range accounts {
+		name := "github token"
+		if len(accounts) > 1 {
+			name = fmt.Sprintf("github account %d", i+1)
+		}
+
+		// the rate limit endpoint doesn't count against the rate limit, and tells if the request is authenticated
+		limits, resp, err := account.client.RateLimit.Get(ctx)
+		if err != nil {
+			diagnosis.add(name, CheckFailed, err.Error())
+			continue
+		}
+
+		if account.user != "" {
+			if _, _, err := account.client.Users.Get(ctx, account.user); err != nil {
+				diagnosis.add(name, CheckFailed, fmt.Sprintf("error getting github user %s: %s", account.user, err))
+				continue
+			}
+		}
+
+		if limits.GetCore().Limit <= unauthenticatedGitHubRateLimit {
+			diagnosis.add(name, CheckWarning, fmt.Sprintf("not authenticated, github allows only %d requests per hour", unauthenticatedGitHubRateLimit))
+			continue
+		}
+
+		// fine-grained tokens don't report their permissions
+		scopesHeader, ok := resp.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+		if !ok {
+			diagnosis.add(name, CheckOK, "authenticated with a token whose scopes can't be checked")
+			continue
+		}
+
+		scopes := parseScopes(strings.Join(scopesHeader, ","))
+		if i == 0 && s.starLists && !slices.Contains(scopes, "read:user") && !slices.Contains(scopes, "user") {
+			diagnosis.add(name, CheckFailed, "the token is missing the read:user scope, required to sync star lists")
+			continue
+		}
+
+		diagnosis.add(name, CheckOK, fmt.Sprintf("authenticated with scopes: %s", strings.Join(scopes, ", ")))
+	}
+}
+
+// parseScopes parses the comma separated scopes of a github token
+func parseScopes(header string) []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(header, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes
+}
+
+// schemaProblems returns all the properties of the database that don't match the required schema
+func (s *Syncer) schemaProblems(database *notionapi.Database) []SchemaProblem {
+	problems := make([]SchemaProblem, 0)
+
+	for _, requiredProperty := range s.requiredProperties() {
+		propertyName := s.mapping.Name(requiredProperty.Field)
+		problem := SchemaProblem{
+			Field:        requiredProperty.Field,
+			Property:     propertyName,
+			ExpectedType: requiredProperty.PropertyType,
+		}
+
+		property, ok := database.Properties[propertyName]
+		if !ok {
+			problems = append(problems, problem)
+			continue
+		}
+
+		if actualType := notionapi.PropertyType(property.GetType()); actualType != requiredProperty.PropertyType {
+			problem.ActualType = actualType
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems
+}
+
+// FixSchema updates the notion database schema to fix the given problems, by adding the missing properties
+// and converting the properties with the wrong type. Converting a property may lose the values that can't be
+// represented in the new type.
+func (s *Syncer) FixSchema(ctx context.Context, notionDatabaseID string, problems []SchemaProblem) error {
+	properties := make(notionapi.PropertyConfigs, len(problems))
+
+	for _, problem := range problems {
+		if !problem.Fixable() {
+			return fmt.Errorf("property %s can't be fixed automatically: rename the title property of the database to %s, or map it with the property mapping", problem.Property, problem.Property)
+		}
+
+		config, err := buildPropertyConfig(problem.ExpectedType, s.listsDatabaseID)
+		if err != nil {
+			return err
+		}
+
+		properties[problem.Property] = config
+	}
+
+	if len(properties) == 0 {
+		return nil
+	}
+
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	_, err := s.notion.Database.Update(ctx, notionapi.DatabaseID(notionDatabaseID), &notionapi.DatabaseUpdateRequest{
+		Properties: properties,
+	})
+	if err != nil {
+		return fmt.Errorf("error updating notion database: %w", err)
+	}
+
+	return nil
+}
+
+// schemaError joins the given schema problems in a single error
+func schemaError(problems []SchemaProblem) error {
+	errs := make([]error, len(problems))
+	for i, problem := range problems {
+		errs[i] = errors.New(problem.String())
+	}
+
+	return errors.Join(errs...)
+}
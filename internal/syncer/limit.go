@@ -0,0 +1,112 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultDeleteLimit is the delete limit used when none is configured
+const DefaultDeleteLimit = "10%"
+
+var ErrInvalidDeleteLimit = errors.New("delete limit must be a number of pages or a percentage, like 10 or 5%")
+
+// DeleteLimit is the maximum number of pages that a sync can remove from the notion database, either as a number of
+// pages or as a percentage of the existing pages. It protects the database from a sync that would archive every page,
+// for example, because the github api returned an empty list of stars.
+type DeleteLimit struct {
+	Pages int
+	// Percent, when greater than zero, takes precedence over Pages
+	Percent float64
+}
+
+// ParseDeleteLimit parses a delete limit written as a number of pages (ex: "10") or as a percentage (ex: "5%")
+func ParseDeleteLimit(value string) (DeleteLimit, error) {
+	value = strings.TrimSpace(value)
+
+	if number, ok := strings.CutSuffix(value, "%"); ok {
+		percent, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return DeleteLimit{}, ErrInvalidDeleteLimit
+		}
+
+		return DeleteLimit{Percent: percent}, nil
+	}
+
+	pages, err := strconv.Atoi(value)
+	if err != nil || pages < 0 {
+		return DeleteLimit{}, ErrInvalidDeleteLimit
+	}
+
+	return DeleteLimit{Pages: pages}, nil
+}
+
+// Max returns the maximum number of pages that can be removed from a database with the given number of pages.
+// A percentage allows at least one page, so that a single unstar isn't refused in small databases.
+func (l DeleteLimit) Max(existing int) int {
+	if l.Percent > 0 {
+		return max(1, int(math.Floor(float64(existing)*l.Percent/100)))
+	}
+
+	return l.Pages
+}
+
+func (l DeleteLimit) String() string {
+	if l.Percent > 0 {
+		return strconv.FormatFloat(l.Percent, 'f', -1, 64) + "%"
+	}
+
+	return strconv.Itoa(l.Pages)
+}
+
+// DeleteLimitError is returned when the plan of a sync removes more pages than the delete limit allows.
+// No change is applied, and the plan is kept so that it can be reviewed.
+type DeleteLimitError struct {
+	Plan *Plan
+	// Deletions is the number of pages that the plan removes from the database
+	Deletions int
+	// Existing is the number of pages of the database
+	Existing int
+	Limit    DeleteLimit
+	// NoStars tells that the plan is refused because a full sync found no starred repos, whatever the limit
+	NoStars bool
+}
+
+func (e *DeleteLimitError) Error() string {
+	if e.NoStars {
+		return fmt.Sprintf("the sync found no starred repos and would remove %d of %d pages", e.Deletions, e.Existing)
+	}
+
+	return fmt.Sprintf("the sync would remove %d of %d pages, which exceeds the delete limit of %s", e.Deletions, e.Existing, e.Limit)
+}
+
+// Deletions returns the number of pages that the plan removes from the notion database, which are the pages archived
+// or moved to the archive database. Marked pages are kept, so they are not counted.
+func (p *Plan) Deletions() int {
+	return p.Count(ActionArchive) + p.Count(ActionMove)
+}
+
+// checkDeleteLimit returns a DeleteLimitError when the plan removes more pages than the delete limit allows
+func (s *Syncer) checkDeleteLimit(plan *Plan) error {
+	if s.deleteLimit == nil {
+		return nil
+	}
+
+	deletions := plan.Deletions()
+	// a full sync without any star is most likely a token that lost its scope or an api error, rather than
+	// someone who unstarred everything, so it's refused even when the limit would allow it
+	noStars := plan.Full && plan.starredRepos == 0 && deletions > 0
+	if !noStars && deletions <= s.deleteLimit.Max(plan.existingPages) {
+		return nil
+	}
+
+	return &DeleteLimitError{
+		Plan:      plan,
+		Deletions: deletions,
+		Existing:  plan.existingPages,
+		Limit:     *s.deleteLimit,
+		NoStars:   noStars,
+	}
+}
@@ -0,0 +1,367 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jomei/notionapi"
+)
+
+// starLists maps the id of each starred repo to the names of the star lists that include it
+type starLists map[int64][]string
+
+// Of returns the names of the lists that include the given repo. It never returns nil, so that repos
+// without lists clear the lists of their pages.
+func (l starLists) Of(repoID int64) []string {
+	if names, ok := l[repoID]; ok {
+		return names
+	}
+
+	return []string{}
+}
+
+// star lists are only available in the github graphql api.
+// The owner of the lists is aliased, so that the same response is used for the authenticated user and other users.
+const starListsQuery = `query($cursor: String%s) {
+  owner: %s {
+    lists(first: 100, after: $cursor) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        id
+        name
+        items(first: 100) {
+          pageInfo { hasNextPage endCursor }
+          nodes { ... on Repository { databaseId } }
+        }
+      }
+    }
+  }
+}`
+
+const starListItemsQuery = `query($id: ID!, $cursor: String) {
+  node(id: $id) {
+    ... on UserList {
+      items(first: 100, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes { ... on Repository { databaseId } }
+      }
+    }
+  }
+}`
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type starListItems struct {
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		DatabaseID int64 `json:"databaseId"`
+	} `json:"nodes"`
+}
+
+type starList struct {
+	ID    string        `json:"id"`
+	Name  string        `json:"name"`
+	Items starListItems `json:"items"`
+}
+
+type starListsResponse struct {
+	Owner struct {
+		Lists struct {
+			PageInfo graphQLPageInfo `json:"pageInfo"`
+			Nodes    []starList      `json:"nodes"`
+		} `json:"lists"`
+	} `json:"owner"`
+}
+
+type starListItemsResponse struct {
+	Node struct {
+		Items starListItems `json:"items"`
+	} `json:"node"`
+}
+
+type graphQLError struct {
+	// Type is the kind of the error, like NOT_FOUND for the objects that don't exist
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// graphQLErrors is returned when the github graphql api answers with errors
+type graphQLErrors []graphQLError
+
+func (e graphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, graphQLErr := range e {
+		messages[i] = graphQLErr.Message
+	}
+
+	return fmt.Sprintf("github graphql error: %s", strings.Join(messages, "; "))
+}
+
+// notFound tells if all the errors are caused by objects that don't exist, in which case the rest of the data
+// is still valid
+func (e graphQLErrors) notFound() bool {
+	for _, graphQLErr := range e {
+		if graphQLErr.Type != "NOT_FOUND" {
+			return false
+		}
+	}
+
+	return true
+}
+
+// fetchGitHubStarLists returns the star lists of the synced user, indexed by repo
+func (s *Syncer) fetchGitHubStarLists(ctx context.Context) (starLists, error) {
+	lists := make(starLists)
+	variables := map[string]any{"cursor": nil}
+
+	query := fmt.Sprintf(starListsQuery, "", "viewer")
+	if s.githubUser != "" {
+		query = fmt.Sprintf(starListsQuery, ", $login: String!", "user(login: $login)")
+		variables["login"] = s.githubUser
+	}
+
+	for {
+		var resp starListsResponse
+		if err := s.githubGraphQL(ctx, query, variables, &resp); err != nil {
+			return nil, err
+		}
+
+		for _, list := range resp.Owner.Lists.Nodes {
+			items := list.Items
+			for {
+				for _, item := range items.Nodes {
+					// items that are not repositories have no database id
+					if item.DatabaseID != 0 {
+						lists[item.DatabaseID] = append(lists[item.DatabaseID], list.Name)
+					}
+				}
+
+				if !items.PageInfo.HasNextPage {
+					break
+				}
+
+				var itemsResp starListItemsResponse
+				err := s.githubGraphQL(ctx, starListItemsQuery, map[string]any{
+					"id":     list.ID,
+					"cursor": items.PageInfo.EndCursor,
+				}, &itemsResp)
+				if err != nil {
+					return nil, err
+				}
+
+				items = itemsResp.Node.Items
+			}
+		}
+
+		if !resp.Owner.Lists.PageInfo.HasNextPage {
+			break
+		}
+
+		variables["cursor"] = resp.Owner.Lists.PageInfo.EndCursor
+	}
+
+	// sort the names, so that the synced properties are stable between runs
+	for _, names := range lists {
+		sort.Strings(names)
+	}
+
+	return lists, nil
+}
+
+// githubGraphQL executes a query against the github graphql api and decodes its data into result.
+// The errors of the response are returned as graphQLErrors, along with the data that could be fetched.
+func (s *Syncer) githubGraphQL(ctx context.Context, query string, variables map[string]any, result any) error {
+	req, err := s.github.NewRequest("POST", "graphql", map[string]any{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Data   any           `json:"data"`
+		Errors graphQLErrors `json:"errors"`
+	}
+	resp.Data = result
+
+	if _, err := s.github.Do(ctx, req, &resp); err != nil {
+		return err
+	}
+
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+
+	return nil
+}
+
+// listsDatabase holds the pages of the notion database that stores the star lists, when the lists are synced
+// to a relation property. It is safe for concurrent use, since missing lists are created while the changes
+// are applied.
+type listsDatabase struct {
+	id            notionapi.DatabaseID
+	titleProperty string
+
+	mu      sync.Mutex
+	pageIDs map[string]string
+	names   map[string]string
+}
+
+// loadListsDatabase fetches the pages of the notion database that stores the star lists
+func (s *Syncer) loadListsDatabase(ctx context.Context) (*listsDatabase, error) {
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	database, err := s.notion.Database.Get(ctx, s.listsDatabaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := &listsDatabase{
+		id:      s.listsDatabaseID,
+		pageIDs: make(map[string]string),
+		names:   make(map[string]string),
+	}
+
+	for name, property := range database.Properties {
+		if property.GetType() == notionapi.PropertyConfigTypeTitle {
+			lists.titleProperty = name
+		}
+	}
+
+	if lists.titleProperty == "" {
+		return nil, errors.New("lists database has no title property")
+	}
+
+	cursor := notionapi.Cursor("")
+	for {
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := s.notion.Database.Query(ctx, s.listsDatabaseID, &notionapi.DatabaseQueryRequest{
+			PageSize:    notionPagesPerPage,
+			StartCursor: cursor,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, page := range resp.Results {
+			if title, ok := page.Properties[lists.titleProperty].(*notionapi.TitleProperty); ok {
+				lists.add(plainText(title.Title), page.ID.String())
+			}
+		}
+
+		if !resp.HasMore {
+			break
+		}
+
+		cursor = resp.NextCursor
+	}
+
+	return lists, nil
+}
+
+func (l *listsDatabase) add(name string, pageID string) {
+	l.pageIDs[name] = pageID
+	l.names[pageID] = name
+}
+
+// resolveNames replaces the ids of the related list pages by the names of the lists.
+// Ids of unknown pages are kept, so that they are replaced on the next update.
+func (l *listsDatabase) resolveNames(pageIDs []string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	names := make([]string, len(pageIDs))
+	for i, pageID := range pageIDs {
+		if name, ok := l.names[pageID]; ok {
+			names[i] = name
+		} else {
+			names[i] = pageID
+		}
+	}
+
+	return names
+}
+
+// relationProperty builds the relation property that links to the pages of the given lists.
+// The pages of missing lists are created in the lists database.
+func (s *Syncer) relationProperty(ctx context.Context, lists *listsDatabase, names []string) (*notionapi.RelationProperty, error) {
+	lists.mu.Lock()
+	defer lists.mu.Unlock()
+
+	relations := make([]notionapi.Relation, len(names))
+	for i, name := range names {
+		pageID, ok := lists.pageIDs[name]
+		if !ok {
+			if err := s.notionLimiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+
+			page, err := s.notion.Page.Create(ctx, &notionapi.PageCreateRequest{
+				Parent: notionapi.Parent{
+					Type:       notionapi.ParentTypeDatabaseID,
+					DatabaseID: lists.id,
+				},
+				Properties: notionapi.Properties{
+					lists.titleProperty: buildTitleProperty(name),
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error creating list %s: %w", name, err)
+			}
+
+			pageID = page.ID.String()
+			lists.add(name, pageID)
+		}
+
+		relations[i] = notionapi.Relation{ID: notionapi.PageID(pageID)}
+	}
+
+	return &notionapi.RelationProperty{Relation: relations}, nil
+}
+
+// resolveListRelations returns a copy of the properties where the lists property, which is built as a multi-select,
+// is replaced by a relation to the pages of the lists database. The properties are returned unchanged when the lists
+// are synced to a multi-select property.
+func (s *Syncer) resolveListRelations(ctx context.Context, lists *listsDatabase, properties notionapi.Properties) (notionapi.Properties, error) {
+	if lists == nil {
+		return properties, nil
+	}
+
+	propertyName := s.mapping.Name(FieldLists)
+	listsProperty, ok := properties[propertyName].(*notionapi.MultiSelectProperty)
+	if !ok {
+		return properties, nil
+	}
+
+	names := make([]string, len(listsProperty.MultiSelect))
+	for i, option := range listsProperty.MultiSelect {
+		names[i] = option.Name
+	}
+
+	relation, err := s.relationProperty(ctx, lists, names)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make(notionapi.Properties, len(properties))
+	for name, property := range properties {
+		resolved[name] = property
+	}
+
+	resolved[propertyName] = relation
+
+	return resolved, nil
+}
@@ -0,0 +1,130 @@
+package syncer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Field identifies a piece of repository information that is synced to a notion database property
+type Field string
+
+const (
+	FieldTitle       Field = "title"
+	FieldCreatedTime Field = "created_time"
+	FieldDescription Field = "description"
+	FieldLanguage    Field = "language"
+	FieldTopics      Field = "topics"
+	FieldRepoURL     Field = "repository_url"
+	FieldRepoID      Field = "repository_id"
+	// FieldLists holds the star lists of the repository. It is only synced when star lists are enabled.
+	FieldLists Field = "lists"
+	// FieldStarredBy holds the accounts that starred the repository. It is only synced when several accounts are used.
+	FieldStarredBy Field = "starred_by"
+	// FieldUnstarred and FieldUnstarredAt flag the pages of unstarred repos. They are only synced when unstarred
+	// pages are marked instead of archived.
+	FieldUnstarred   Field = "unstarred"
+	FieldUnstarredAt Field = "unstarred_at"
+	// FieldProtected is an optional checkbox that prevents the sync from changing a page
+	FieldProtected Field = "protected"
+	// FieldStars, FieldForks and FieldOpenIssues hold the statistics of the repository.
+	// They are optional, and only synced when the database has them. The watchers are not synced, since the
+	// starred repos api only returns watchers_count, which is the number of stargazers, and not subscribers_count.
+	FieldStars      Field = "stars"
+	FieldForks      Field = "forks"
+	FieldOpenIssues Field = "open_issues"
+	// FieldArchived, FieldFork, FieldTemplate, FieldPushedAt, FieldRepoCreatedAt and FieldRepoUpdatedAt hold the
+	// lifecycle of the repository. They are optional, and only synced when the database has them.
+	FieldArchived      Field = "archived"
+	FieldFork          Field = "fork"
+	FieldTemplate      Field = "template"
+	FieldPushedAt      Field = "pushed_at"
+	FieldRepoCreatedAt Field = "repository_created_at"
+	FieldRepoUpdatedAt Field = "repository_updated_at"
+	// FieldStarredAt holds the date the repository was starred. It is optional, and only synced when the database has it.
+	FieldStarredAt Field = "starred_at"
+	// FieldOwner and FieldOwnerType hold the owner of the repository and whether it is a user or an organization.
+	// They are optional, and only synced when the database has them.
+	FieldOwner     Field = "owner"
+	FieldOwnerType Field = "owner_type"
+)
+
+// defaultPropertyNames holds the property names used when a field is not present in the mapping
+var defaultPropertyNames = map[Field]string{
+	FieldTitle:         databasePropertyTitle,
+	FieldCreatedTime:   databasePropertyCreatedTime,
+	FieldDescription:   databasePropertyDescription,
+	FieldLanguage:      databasePropertyLanguage,
+	FieldTopics:        databasePropertyTopics,
+	FieldRepoURL:       databasePropertyRepoURL,
+	FieldRepoID:        databasePropertyRepoID,
+	FieldLists:         databasePropertyLists,
+	FieldStarredBy:     databasePropertyStarredBy,
+	FieldUnstarred:     databasePropertyUnstarred,
+	FieldUnstarredAt:   databasePropertyUnstarredAt,
+	FieldProtected:     databasePropertyProtected,
+	FieldStars:         databasePropertyStars,
+	FieldForks:         databasePropertyForks,
+	FieldOpenIssues:    databasePropertyOpenIssues,
+	FieldArchived:      databasePropertyArchived,
+	FieldFork:          databasePropertyFork,
+	FieldTemplate:      databasePropertyTemplate,
+	FieldPushedAt:      databasePropertyPushedAt,
+	FieldRepoCreatedAt: databasePropertyRepoCreatedAt,
+	FieldRepoUpdatedAt: databasePropertyRepoUpdatedAt,
+	FieldStarredAt:     databasePropertyStarredAt,
+	FieldOwner:         databasePropertyOwner,
+	FieldOwnerType:     databasePropertyOwnerType,
+}
+
+// PropertyMapping maps the synced fields to the names of the notion database properties that store them.
+// This allows to use databases whose columns have different names, for example, in another language.
+// Fields that are not present in the mapping use the default property name.
+type PropertyMapping map[Field]string
+
+// DefaultPropertyMapping returns a mapping with the default property name of every field
+func DefaultPropertyMapping() PropertyMapping {
+	mapping := make(PropertyMapping, len(defaultPropertyNames))
+	for field, name := range defaultPropertyNames {
+		mapping[field] = name
+	}
+
+	return mapping
+}
+
+// Name returns the name of the notion property that stores the given field
+func (m PropertyMapping) Name(field Field) string {
+	if name, ok := m[field]; ok && name != "" {
+		return name
+	}
+
+	return defaultPropertyNames[field]
+}
+
+// Validate checks that the mapping only contains known fields and that each property is used by a single field
+func (m PropertyMapping) Validate() error {
+	for field := range m {
+		if _, ok := defaultPropertyNames[field]; !ok {
+			return fmt.Errorf("unknown property mapping field %q", field)
+		}
+	}
+
+	fields := make([]string, 0, len(defaultPropertyNames))
+	for field := range defaultPropertyNames {
+		fields = append(fields, string(field))
+	}
+
+	// sort the fields, so that the error message is deterministic
+	sort.Strings(fields)
+
+	usedBy := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		name := m.Name(Field(field))
+		if other, ok := usedBy[name]; ok {
+			return fmt.Errorf("notion property %q is mapped to both %s and %s fields", name, other, field)
+		}
+
+		usedBy[name] = Field(field)
+	}
+
+	return nil
+}
@@ -1,68 +1,195 @@
 package syncer
 
 import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/jomei/notionapi"
 )
 
+// default names of the notion database properties
 const (
-	databasePropertyTitle       = "Name"
-	databasePropertyCreatedTime = "Created time"
-	databasePropertyDescription = "Description"
-	databasePropertyLanguage    = "Language"
-	databasePropertyTopics      = "Topics"
-	databasePropertyRepoURL     = "Repository URL"
-	databasePropertyRepoID      = "Repository ID"
+	databasePropertyTitle         = "Name"
+	databasePropertyCreatedTime   = "Created time"
+	databasePropertyDescription   = "Description"
+	databasePropertyLanguage      = "Language"
+	databasePropertyTopics        = "Topics"
+	databasePropertyRepoURL       = "Repository URL"
+	databasePropertyRepoID        = "Repository ID"
+	databasePropertyLists         = "Lists"
+	databasePropertyStarredBy     = "Starred by"
+	databasePropertyUnstarred     = "Unstarred"
+	databasePropertyUnstarredAt   = "Unstarred at"
+	databasePropertyProtected     = "Protected"
+	databasePropertyStars         = "Stars"
+	databasePropertyForks         = "Forks"
+	databasePropertyOpenIssues    = "Open issues"
+	databasePropertyArchived      = "Archived"
+	databasePropertyFork          = "Fork"
+	databasePropertyTemplate      = "Template"
+	databasePropertyPushedAt      = "Pushed at"
+	databasePropertyRepoCreatedAt = "Repository created at"
+	databasePropertyRepoUpdatedAt = "Repository updated at"
+	databasePropertyStarredAt     = "Starred at"
+	databasePropertyOwner         = "Owner"
+	databasePropertyOwnerType     = "Owner type"
 )
 
 // RequiredProperty represents a required property for the notion database
 type RequiredProperty struct {
-	PropertyName string
+	Field        Field
 	PropertyType notionapi.PropertyType
 }
 
 var requiredProperties = []RequiredProperty{
 	{
-		PropertyName: databasePropertyCreatedTime,
+		Field:        FieldCreatedTime,
 		PropertyType: notionapi.PropertyTypeCreatedTime,
 	},
 	{
-		PropertyName: databasePropertyDescription,
+		Field:        FieldDescription,
 		PropertyType: notionapi.PropertyTypeRichText,
 	},
 	{
-		PropertyName: databasePropertyLanguage,
+		Field:        FieldLanguage,
 		PropertyType: notionapi.PropertyTypeSelect,
 	},
 	{
-		PropertyName: databasePropertyTopics,
+		Field:        FieldTopics,
 		PropertyType: notionapi.PropertyTypeMultiSelect,
 	},
 	{
-		PropertyName: databasePropertyTitle,
+		Field:        FieldTitle,
 		PropertyType: notionapi.PropertyTypeTitle,
 	},
 	{
-		PropertyName: databasePropertyRepoID,
+		Field:        FieldRepoID,
 		PropertyType: notionapi.PropertyTypeNumber,
 	},
 	{
-		PropertyName: databasePropertyRepoURL,
+		Field:        FieldRepoURL,
 		PropertyType: notionapi.PropertyTypeURL,
 	},
 }
 
+// optionalProperties are the properties that are only synced when the notion database has them, with the right type.
+// They allow each database to store only the information it needs.
+var optionalProperties = []RequiredProperty{
+	{
+		Field:        FieldStars,
+		PropertyType: notionapi.PropertyTypeNumber,
+	},
+	{
+		Field:        FieldForks,
+		PropertyType: notionapi.PropertyTypeNumber,
+	},
+	{
+		Field:        FieldOpenIssues,
+		PropertyType: notionapi.PropertyTypeNumber,
+	},
+	{
+		Field:        FieldArchived,
+		PropertyType: notionapi.PropertyTypeCheckbox,
+	},
+	{
+		Field:        FieldFork,
+		PropertyType: notionapi.PropertyTypeCheckbox,
+	},
+	{
+		Field:        FieldTemplate,
+		PropertyType: notionapi.PropertyTypeCheckbox,
+	},
+	{
+		Field:        FieldPushedAt,
+		PropertyType: notionapi.PropertyTypeDate,
+	},
+	{
+		Field:        FieldRepoCreatedAt,
+		PropertyType: notionapi.PropertyTypeDate,
+	},
+	{
+		Field:        FieldRepoUpdatedAt,
+		PropertyType: notionapi.PropertyTypeDate,
+	},
+	{
+		Field:        FieldStarredAt,
+		PropertyType: notionapi.PropertyTypeDate,
+	},
+	{
+		Field:        FieldOwner,
+		PropertyType: notionapi.PropertyTypeSelect,
+	},
+	{
+		Field:        FieldOwnerType,
+		PropertyType: notionapi.PropertyTypeSelect,
+	},
+}
+
+// alternativePropertyTypes are the other types accepted for some optional properties
+var alternativePropertyTypes = map[Field][]notionapi.PropertyType{
+	FieldOwner: {notionapi.PropertyTypeRichText},
+}
+
+// optionalFields holds the type of the optional fields whose property exists in the notion database
+type optionalFields map[Field]notionapi.PropertyType
+
+// Has tells if the property of the given optional field exists in the notion database
+func (f optionalFields) Has(field Field) bool {
+	_, ok := f[field]
+	return ok
+}
+
 // databasePages is a struct that holds all the existing notion pages.
 // It is useful to have this wrapper instead of using a slice directly, because it allows us to add some helper methods
 // to the collection itself, like for example, checking if a github repository already exists in the collection
 type databasePages struct {
 	Pages []notionPage
+	// Malformed holds the pages that can't be matched with a repo
+	Malformed []MalformedPage
+	// unidentified holds the parsed properties of each malformed page, in the same order
+	unidentified []notionPage
+}
+
+// MalformedPage is a page of the notion database that can't be matched with a repo, like a row added by hand
+// without a repository id. Malformed pages are reported, and never changed by the sync.
+type MalformedPage struct {
+	PageID  string `json:"page_id"`
+	PageURL string `json:"page_url"`
+	Title   string `json:"title,omitempty"`
+	Reason  string `json:"reason"`
 }
 
 // notionPage is a small representation of a notion page. It holds only the required information for syncing
 type notionPage struct {
-	ID       string
-	Title    string
-	GitHubID int64
+	ID          string
+	Title       string
+	GitHubID    int64
+	Description string
+	Language    string
+	Topics      []string
+	URL         string
+	// Lists holds the names of the star lists of the page. When the lists are stored as a relation,
+	// it holds the ids of the related pages until they are resolved to the list names.
+	Lists []string
+	// StarredBy holds the names of the accounts that starred the repo of the page
+	StarredBy []string
+	StarredAt time.Time
+	// Unstarred tells if the page was marked as unstarred by a previous sync
+	Unstarred bool
+	// Protected pages are never changed by the sync
+	Protected bool
+	Stats     repoStats
+	Lifecycle repoLifecycle
+	Owner     string
+	OwnerType string
+	// AvatarIcon is the url of the icon of the page, when it is the avatar of a github account
+	AvatarIcon string
+	// CustomIcon tells if the page has an icon that is not a github avatar, which is never replaced
+	CustomIcon bool
 }
 
 func newDatabasePages() *databasePages {
@@ -76,74 +203,591 @@ func (c *databasePages) Add(page notionPage) {
 	c.Pages = append(c.Pages, page)
 }
 
-// ContainsRepo checks if a github repository already exists in the collection
-func (c *databasePages) ContainsRepo(repoID int64) bool {
-	for _, page := range c.Pages {
-		if page.GitHubID == repoID {
-			return true
+// AddMalformed adds a page that can't be matched with a repo to the collection, with the reason why
+func (c *databasePages) AddMalformed(page notionapi.Page, parsed notionPage, err error) {
+	c.Malformed = append(c.Malformed, MalformedPage{
+		PageID:  page.ID.String(),
+		PageURL: page.URL,
+		Title:   parsed.Title,
+		Reason:  err.Error(),
+	})
+	c.unidentified = append(c.unidentified, parsed)
+}
+
+// Adopt finds the malformed page that holds the given repo without its repository id, like the pages of a database
+// curated by hand, by matching its url or, failing that, its "owner/name" title. The page is removed from the
+// malformed pages and returned with the id of the repo.
+func (c *databasePages) Adopt(repo *starredRepo) (*notionPage, bool) {
+	repoURL := normalizeRepoURL(repo.URL)
+	fullName := repo.Owner + "/" + repo.Name
+
+	match := slices.IndexFunc(c.unidentified, func(page notionPage) bool {
+		return repoURL != "" && normalizeRepoURL(page.URL) == repoURL
+	})
+
+	if match < 0 && repo.Owner != "" {
+		match = slices.IndexFunc(c.unidentified, func(page notionPage) bool {
+			return strings.EqualFold(strings.TrimSpace(page.Title), fullName)
+		})
+	}
+
+	if match < 0 {
+		return nil, false
+	}
+
+	page := c.unidentified[match]
+	page.GitHubID = repo.ID
+
+	c.unidentified = slices.Delete(c.unidentified, match, match+1)
+	c.Malformed = slices.Delete(c.Malformed, match, match+1)
+
+	return &page, true
+}
+
+// normalizeRepoURL returns the github url in a form that can be compared, without its scheme, "www." prefix,
+// ".git" suffix, trailing slashes, query and fragment, in lowercase since github names are case insensitive
+func normalizeRepoURL(rawURL string) string {
+	normalized := strings.ToLower(strings.TrimSpace(rawURL))
+
+	if i := strings.IndexAny(normalized, "?#"); i >= 0 {
+		normalized = normalized[:i]
+	}
+
+	normalized = strings.TrimPrefix(normalized, "https://")
+	normalized = strings.TrimPrefix(normalized, "http://")
+	normalized = strings.TrimPrefix(normalized, "www.")
+	normalized = strings.TrimRight(normalized, "/")
+	normalized = strings.TrimSuffix(normalized, ".git")
+
+	return normalized
+}
+
+// createdBySync tells if the page can be positively identified as the page of a repo created by the sync, which sets
+// both its repository id and its github url
+func (p *notionPage) createdBySync() bool {
+	return p.GitHubID > 0 && strings.HasPrefix(p.URL, "https://github.com/")
+}
+
+// Duplicates returns the groups of pages that hold the same github repository, in the order of the collection.
+// Only the first page of each group is synced, the others are left untouched.
+func (c *databasePages) Duplicates() [][]*notionPage {
+	groups := make(map[int64][]*notionPage)
+	order := make([]int64, 0)
+
+	for i := range c.Pages {
+		repoID := c.Pages[i].GitHubID
+		// pages without a repository id are not synced, so they can't be duplicates of each other
+		if repoID <= 0 {
+			continue
+		}
+
+		if _, ok := groups[repoID]; !ok {
+			order = append(order, repoID)
+		}
+
+		groups[repoID] = append(groups[repoID], &c.Pages[i])
+	}
+
+	duplicates := make([][]*notionPage, 0)
+	for _, repoID := range order {
+		if len(groups[repoID]) > 1 {
+			duplicates = append(duplicates, groups[repoID])
+		}
+	}
+
+	return duplicates
+}
+
+// GetByRepo returns the page that holds the given github repository, if it exists in the collection
+func (c *databasePages) GetByRepo(repoID int64) (*notionPage, bool) {
+	for i := range c.Pages {
+		if c.Pages[i].GitHubID == repoID {
+			return &c.Pages[i], true
 		}
 	}
 
-	return false
+	return nil, false
 }
 
 // buildCreatePageRequestFromRepo builds a notion page create request from a starred repo object
-func buildCreatePageRequestFromRepo(databaseID notionapi.DatabaseID, repo *starredRepo) *notionapi.PageCreateRequest {
-	topicsProperty := make([]notionapi.Option, len(repo.Topics))
+func buildCreatePageRequestFromRepo(databaseID notionapi.DatabaseID, repo *starredRepo, mapping PropertyMapping, fields optionalFields) *notionapi.PageCreateRequest {
+	request := &notionapi.PageCreateRequest{
+		Parent: notionapi.Parent{
+			Type:       notionapi.ParentTypeDatabaseID,
+			DatabaseID: databaseID,
+		},
+		Properties: buildPagePropertiesFromRepo(repo, mapping, fields),
+		Icon:       buildAvatarIcon(repo),
+	}
+
+	return request
+}
+
+// buildPagePropertiesFromRepo builds the notion page properties that hold the information of a starred repo.
+// The optional properties are only included when the database has them.
+func buildPagePropertiesFromRepo(repo *starredRepo, mapping PropertyMapping, fields optionalFields) notionapi.Properties {
+	properties := notionapi.Properties{
+		mapping.Name(FieldTitle):       buildTitleProperty(repo.Name),
+		mapping.Name(FieldDescription): buildRichTextProperty(repo.Description),
+		mapping.Name(FieldRepoURL): &notionapi.URLProperty{
+			URL: repo.URL,
+		},
+		mapping.Name(FieldRepoID): &notionapi.NumberProperty{
+			Number: float64(repo.ID),
+		},
+		mapping.Name(FieldTopics): buildMultiSelectProperty(repo.Topics),
+	}
+
+	if repo.Language != "" {
+		properties[mapping.Name(FieldLanguage)] = buildSelectProperty(repo.Language)
+	}
+
+	if repo.Lists != nil {
+		properties[mapping.Name(FieldLists)] = buildMultiSelectProperty(repo.Lists)
+	}
+
+	if repo.StarredBy != nil {
+		properties[mapping.Name(FieldStarredBy)] = buildMultiSelectProperty(repo.StarredBy)
+	}
 
-	for i, topic := range repo.Topics {
-		topicsProperty[i] = notionapi.Option{
-			Name: topic,
+	for field, value := range repo.Stats.byField() {
+		if fields.Has(field) {
+			properties[mapping.Name(field)] = &notionapi.NumberProperty{Number: float64(value)}
 		}
 	}
 
-	properties := notionapi.Properties{
-		databasePropertyTitle: &notionapi.TitleProperty{
-			Title: []notionapi.RichText{
-				{
-					Type: notionapi.ObjectTypeText,
-					Text: &notionapi.Text{
-						Content: repo.Name,
-					},
+	for field, value := range repo.Lifecycle.checkboxes() {
+		if fields.Has(field) {
+			properties[mapping.Name(field)] = &notionapi.CheckboxProperty{Checkbox: value}
+		}
+	}
+
+	for field, value := range repo.Lifecycle.dates() {
+		if fields.Has(field) && !value.IsZero() {
+			properties[mapping.Name(field)] = buildDateProperty(value)
+		}
+	}
+
+	if fields.Has(FieldStarredAt) && !repo.StarredAt.IsZero() {
+		properties[mapping.Name(FieldStarredAt)] = buildDateProperty(repo.StarredAt)
+	}
+
+	if fields.Has(FieldOwner) {
+		properties[mapping.Name(FieldOwner)] = buildOwnerProperty(repo.Owner, fields[FieldOwner])
+	}
+
+	if fields.Has(FieldOwnerType) && repo.OwnerType != "" {
+		properties[mapping.Name(FieldOwnerType)] = buildSelectProperty(repo.OwnerType)
+	}
+
+	return properties
+}
+
+// buildStatsProperties returns the statistics properties of the page that are out of date with the given statistics
+func buildStatsProperties(page *notionPage, stats repoStats, mapping PropertyMapping, fields optionalFields) notionapi.Properties {
+	properties := notionapi.Properties{}
+
+	pageStats := page.Stats.byField()
+	for field, value := range stats.byField() {
+		if fields.Has(field) && pageStats[field] != value {
+			properties[mapping.Name(field)] = &notionapi.NumberProperty{Number: float64(value)}
+		}
+	}
+
+	return properties
+}
+
+// buildUpdatePageRequestFromRepo builds a notion page update request containing only the properties of the page that
+// are out of date with the starred repo. It returns nil if the page is already up to date.
+func buildUpdatePageRequestFromRepo(page *notionPage, repo *starredRepo, mapping PropertyMapping, fields optionalFields) *notionapi.PageUpdateRequest {
+	properties := notionapi.Properties{}
+
+	if page.Title != repo.Name {
+		properties[mapping.Name(FieldTitle)] = buildTitleProperty(repo.Name)
+	}
+
+	if page.Description != repo.Description {
+		properties[mapping.Name(FieldDescription)] = buildRichTextProperty(repo.Description)
+	}
+
+	if page.URL != repo.URL {
+		properties[mapping.Name(FieldRepoURL)] = &notionapi.URLProperty{
+			URL: repo.URL,
+		}
+	}
+
+	if page.Language != repo.Language {
+		if repo.Language == "" {
+			properties[mapping.Name(FieldLanguage)] = &emptySelectProperty{}
+		} else {
+			properties[mapping.Name(FieldLanguage)] = buildSelectProperty(repo.Language)
+		}
+	}
+
+	if !sameOptions(page.Topics, repo.Topics) {
+		properties[mapping.Name(FieldTopics)] = buildMultiSelectProperty(repo.Topics)
+	}
+
+	if repo.Lists != nil && !sameOptions(page.Lists, repo.Lists) {
+		properties[mapping.Name(FieldLists)] = buildMultiSelectProperty(repo.Lists)
+	}
+
+	if repo.StarredBy != nil && !sameOptions(page.StarredBy, repo.StarredBy) {
+		properties[mapping.Name(FieldStarredBy)] = buildMultiSelectProperty(repo.StarredBy)
+	}
+
+	maps.Copy(properties, buildStatsProperties(page, repo.Stats, mapping, fields))
+
+	pageCheckboxes := page.Lifecycle.checkboxes()
+	for field, value := range repo.Lifecycle.checkboxes() {
+		if fields.Has(field) && pageCheckboxes[field] != value {
+			properties[mapping.Name(field)] = &notionapi.CheckboxProperty{Checkbox: value}
+		}
+	}
+
+	pageDates := page.Lifecycle.dates()
+	for field, value := range repo.Lifecycle.dates() {
+		if fields.Has(field) && !sameMinute(pageDates[field], value) {
+			properties[mapping.Name(field)] = buildDateProperty(value)
+		}
+	}
+
+	// pages created before the property was added to the database are backfilled with the date of the star
+	if fields.Has(FieldStarredAt) && !repo.StarredAt.IsZero() && !sameMinute(page.StarredAt, repo.StarredAt) {
+		properties[mapping.Name(FieldStarredAt)] = buildDateProperty(repo.StarredAt)
+	}
+
+	if fields.Has(FieldOwner) && page.Owner != repo.Owner {
+		properties[mapping.Name(FieldOwner)] = buildOwnerProperty(repo.Owner, fields[FieldOwner])
+	}
+
+	if fields.Has(FieldOwnerType) && repo.OwnerType != "" && page.OwnerType != repo.OwnerType {
+		properties[mapping.Name(FieldOwnerType)] = buildSelectProperty(repo.OwnerType)
+	}
+
+	// the repo was starred again since its page was marked as unstarred
+	if page.Unstarred {
+		properties[mapping.Name(FieldUnstarred)] = &notionapi.CheckboxProperty{Checkbox: false}
+		properties[mapping.Name(FieldUnstarredAt)] = &emptyDateProperty{}
+	}
+
+	request := &notionapi.PageUpdateRequest{
+		Properties: properties,
+	}
+
+	// icons chosen by hand are kept, and the avatar is only replaced when the owner changed it
+	if !page.CustomIcon && page.AvatarIcon != repo.OwnerAvatarURL {
+		request.Icon = buildAvatarIcon(repo)
+	}
+
+	if len(properties) == 0 && request.Icon == nil {
+		return nil
+	}
+
+	return request
+}
+
+// parseNotionPage extracts the synced properties from a notion page returned by the API.
+// It returns an error when the page has no valid repository id, since the page can't be matched with a repo.
+// The page is still returned with its other properties, so that it can be reported or adopted.
+func parseNotionPage(page notionapi.Page, mapping PropertyMapping) (notionPage, error) {
+	result := notionPage{
+		ID: page.ID.String(),
+	}
+
+	// pages added by hand may have an empty title
+	if titleProperty, ok := page.Properties[mapping.Name(FieldTitle)].(*notionapi.TitleProperty); ok {
+		result.Title = plainText(titleProperty.Title)
+	}
+
+	if descriptionProperty, ok := page.Properties[mapping.Name(FieldDescription)].(*notionapi.RichTextProperty); ok {
+		result.Description = plainText(descriptionProperty.RichText)
+	}
+
+	if languageProperty, ok := page.Properties[mapping.Name(FieldLanguage)].(*notionapi.SelectProperty); ok {
+		result.Language = languageProperty.Select.Name
+	}
+
+	if topicsProperty, ok := page.Properties[mapping.Name(FieldTopics)].(*notionapi.MultiSelectProperty); ok {
+		result.Topics = make([]string, len(topicsProperty.MultiSelect))
+		for i, option := range topicsProperty.MultiSelect {
+			result.Topics[i] = option.Name
+		}
+	}
+
+	if urlProperty, ok := page.Properties[mapping.Name(FieldRepoURL)].(*notionapi.URLProperty); ok {
+		result.URL = urlProperty.URL
+	}
+
+	if starredByProperty, ok := page.Properties[mapping.Name(FieldStarredBy)].(*notionapi.MultiSelectProperty); ok {
+		result.StarredBy = make([]string, len(starredByProperty.MultiSelect))
+		for i, option := range starredByProperty.MultiSelect {
+			result.StarredBy[i] = option.Name
+		}
+	}
+
+	if unstarredProperty, ok := page.Properties[mapping.Name(FieldUnstarred)].(*notionapi.CheckboxProperty); ok {
+		result.Unstarred = unstarredProperty.Checkbox
+	}
+
+	if protectedProperty, ok := page.Properties[mapping.Name(FieldProtected)].(*notionapi.CheckboxProperty); ok {
+		result.Protected = protectedProperty.Checkbox
+	}
+
+	result.Stats = repoStats{
+		Stars:      numberValue(page.Properties, mapping.Name(FieldStars)),
+		Forks:      numberValue(page.Properties, mapping.Name(FieldForks)),
+		OpenIssues: numberValue(page.Properties, mapping.Name(FieldOpenIssues)),
+	}
+
+	result.Lifecycle = repoLifecycle{
+		Archived:  checkboxValue(page.Properties, mapping.Name(FieldArchived)),
+		Fork:      checkboxValue(page.Properties, mapping.Name(FieldFork)),
+		Template:  checkboxValue(page.Properties, mapping.Name(FieldTemplate)),
+		PushedAt:  dateValue(page.Properties, mapping.Name(FieldPushedAt)),
+		CreatedAt: dateValue(page.Properties, mapping.Name(FieldRepoCreatedAt)),
+		UpdatedAt: dateValue(page.Properties, mapping.Name(FieldRepoUpdatedAt)),
+	}
+
+	result.StarredAt = dateValue(page.Properties, mapping.Name(FieldStarredAt))
+
+	switch ownerProperty := page.Properties[mapping.Name(FieldOwner)].(type) {
+	case *notionapi.SelectProperty:
+		result.Owner = ownerProperty.Select.Name
+	case *notionapi.RichTextProperty:
+		result.Owner = plainText(ownerProperty.RichText)
+	}
+
+	if ownerTypeProperty, ok := page.Properties[mapping.Name(FieldOwnerType)].(*notionapi.SelectProperty); ok {
+		result.OwnerType = ownerTypeProperty.Select.Name
+	}
+
+	if page.Icon != nil {
+		if page.Icon.External != nil && isGitHubAvatar(page.Icon.External.URL) {
+			result.AvatarIcon = page.Icon.External.URL
+		} else {
+			result.CustomIcon = true
+		}
+	}
+
+	switch listsProperty := page.Properties[mapping.Name(FieldLists)].(type) {
+	case *notionapi.MultiSelectProperty:
+		result.Lists = make([]string, len(listsProperty.MultiSelect))
+		for i, option := range listsProperty.MultiSelect {
+			result.Lists[i] = option.Name
+		}
+	case *notionapi.RelationProperty:
+		result.Lists = make([]string, len(listsProperty.Relation))
+		for i, relation := range listsProperty.Relation {
+			result.Lists[i] = relation.ID.String()
+		}
+	}
+
+	repoIDProperty, ok := page.Properties[mapping.Name(FieldRepoID)].(*notionapi.NumberProperty)
+	if !ok {
+		return result, fmt.Errorf("property %s is missing", mapping.Name(FieldRepoID))
+	}
+
+	// an empty number property is returned as zero, and repository ids are positive integers
+	if repoIDProperty.Number <= 0 || repoIDProperty.Number != math.Trunc(repoIDProperty.Number) {
+		return result, fmt.Errorf("property %s has no valid repository id", mapping.Name(FieldRepoID))
+	}
+
+	result.GitHubID = int64(repoIDProperty.Number)
+
+	return result, nil
+}
+
+func buildTitleProperty(content string) *notionapi.TitleProperty {
+	return &notionapi.TitleProperty{
+		Title: []notionapi.RichText{
+			{
+				Type: notionapi.ObjectTypeText,
+				Text: &notionapi.Text{
+					Content: content,
 				},
 			},
 		},
-		databasePropertyDescription: &notionapi.RichTextProperty{
-			RichText: []notionapi.RichText{
-				{
-					Type: notionapi.ObjectTypeText,
-					Text: &notionapi.Text{
-						Content: repo.Description,
-					},
+	}
+}
+
+func buildRichTextProperty(content string) *notionapi.RichTextProperty {
+	return &notionapi.RichTextProperty{
+		RichText: []notionapi.RichText{
+			{
+				Type: notionapi.ObjectTypeText,
+				Text: &notionapi.Text{
+					Content: content,
 				},
 			},
 		},
-		databasePropertyRepoURL: &notionapi.URLProperty{
-			URL: repo.URL,
-		},
-		databasePropertyRepoID: &notionapi.NumberProperty{
-			Number: float64(repo.ID),
-		},
-		databasePropertyTopics: &notionapi.MultiSelectProperty{
-			MultiSelect: topicsProperty,
+	}
+}
+
+func buildSelectProperty(name string) *notionapi.SelectProperty {
+	return &notionapi.SelectProperty{
+		Select: notionapi.Option{
+			Name: name,
 		},
 	}
+}
 
-	if repo.Language != "" {
-		properties[databasePropertyLanguage] = &notionapi.SelectProperty{
-			Select: notionapi.Option{
-				Name: repo.Language,
-			},
+// buildOwnerProperty builds the property that holds the owner of a repo, which is either a select or a rich text
+func buildOwnerProperty(owner string, propertyType notionapi.PropertyType) notionapi.Property {
+	if propertyType == notionapi.PropertyTypeRichText {
+		return buildRichTextProperty(owner)
+	}
+
+	return buildSelectProperty(owner)
+}
+
+// buildAvatarIcon builds a page icon with the avatar of the owner of the repo, or nil when the avatar is unknown
+func buildAvatarIcon(repo *starredRepo) *notionapi.Icon {
+	if repo.OwnerAvatarURL == "" {
+		return nil
+	}
+
+	return &notionapi.Icon{
+		Type:     notionapi.FileTypeExternal,
+		External: &notionapi.FileObject{URL: repo.OwnerAvatarURL},
+	}
+}
+
+// isGitHubAvatar tells if the url is the avatar of a github account
+func isGitHubAvatar(url string) bool {
+	return strings.HasPrefix(url, "https://avatars.githubusercontent.com/")
+}
+
+// buildDateProperty builds a date property with the given time, or an empty one when the time is zero
+func buildDateProperty(value time.Time) notionapi.Property {
+	if value.IsZero() {
+		return &emptyDateProperty{}
+	}
+
+	date := notionapi.Date(value)
+
+	return &notionapi.DateProperty{
+		Date: &notionapi.DateObject{Start: &date},
+	}
+}
+
+func buildMultiSelectProperty(names []string) *notionapi.MultiSelectProperty {
+	options := make([]notionapi.Option, len(names))
+
+	for i, name := range names {
+		options[i] = notionapi.Option{
+			Name: optionName(name),
 		}
 	}
 
-	request := &notionapi.PageCreateRequest{
-		Parent: notionapi.Parent{
-			Type:       notionapi.ParentTypeDatabaseID,
-			DatabaseID: databaseID,
-		},
-		Properties: properties,
+	return &notionapi.MultiSelectProperty{
+		MultiSelect: options,
 	}
+}
 
-	return request
+// emptySelectProperty clears the value of a select property.
+// notionapi.SelectProperty always serializes the option as an object, but the notion api requires "null" to unset it.
+type emptySelectProperty struct{}
+
+func (p emptySelectProperty) GetID() string {
+	return ""
+}
+
+func (p emptySelectProperty) GetType() notionapi.PropertyType {
+	return notionapi.PropertyTypeSelect
+}
+
+func (p emptySelectProperty) MarshalJSON() ([]byte, error) {
+	return []byte(`{"select":null}`), nil
+}
+
+// emptyDateProperty clears the value of a date property, for the same reason as emptySelectProperty
+type emptyDateProperty struct{}
+
+func (p emptyDateProperty) GetID() string {
+	return ""
+}
+
+func (p emptyDateProperty) GetType() notionapi.PropertyType {
+	return notionapi.PropertyTypeDate
+}
+
+func (p emptyDateProperty) MarshalJSON() ([]byte, error) {
+	return []byte(`{"date":null}`), nil
+}
+
+// numberValue returns the value of the number property with the given name, or zero when the page doesn't have it
+func numberValue(properties notionapi.Properties, name string) int {
+	if numberProperty, ok := properties[name].(*notionapi.NumberProperty); ok {
+		return int(numberProperty.Number)
+	}
+
+	return 0
+}
+
+// checkboxValue returns the value of the checkbox property with the given name, or false when the page doesn't have it
+func checkboxValue(properties notionapi.Properties, name string) bool {
+	if checkboxProperty, ok := properties[name].(*notionapi.CheckboxProperty); ok {
+		return checkboxProperty.Checkbox
+	}
+
+	return false
+}
+
+// dateValue returns the start of the date property with the given name, or the zero time when the page doesn't have it
+func dateValue(properties notionapi.Properties, name string) time.Time {
+	if dateProperty, ok := properties[name].(*notionapi.DateProperty); ok && dateProperty.Date != nil && dateProperty.Date.Start != nil {
+		return time.Time(*dateProperty.Date.Start)
+	}
+
+	return time.Time{}
+}
+
+// sameMinute checks if two times are equal to the minute, which is the precision of the dates shown by notion
+func sameMinute(a, b time.Time) bool {
+	return a.Truncate(time.Minute).Equal(b.Truncate(time.Minute))
+}
+
+// plainText concatenates the plain text of a list of rich text objects
+func plainText(richText []notionapi.RichText) string {
+	var sb strings.Builder
+
+	for _, text := range richText {
+		if text.PlainText != "" {
+			sb.WriteString(text.PlainText)
+		} else if text.Text != nil {
+			sb.WriteString(text.Text.Content)
+		}
+	}
+
+	return sb.String()
+}
+
+// optionName returns the name of a select option written to notion. Notion rejects the commas in option names,
+// so they are replaced with semicolons, like "Go; Rust" for a star list named "Go, Rust".
+func optionName(name string) string {
+	return strings.ReplaceAll(name, ",", ";")
+}
+
+// sameOptions checks if two lists of option names contain the same elements, regardless of their order.
+// The names are compared as they are written to notion.
+func sameOptions(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	sortedA := make([]string, len(a))
+	for i, name := range a {
+		sortedA[i] = optionName(name)
+	}
+
+	sortedB := make([]string, len(b))
+	for i, name := range b {
+		sortedB[i] = optionName(name)
+	}
+
+	slices.Sort(sortedA)
+	slices.Sort(sortedB)
+
+	return slices.Equal(sortedA, sortedB)
 }
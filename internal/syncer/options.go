@@ -0,0 +1,127 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/google/go-github/v57/github"
+	"github.com/jomei/notionapi"
+	"golang.org/x/time/rate"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/state"
+)
+
+// Option allows to customize the behavior of the Syncer
+type Option func(*Syncer)
+
+// WithPropertyMapping overrides the names of the notion database properties used to store the synced fields
+func WithPropertyMapping(mapping PropertyMapping) Option {
+	return func(s *Syncer) {
+		s.mapping = mapping
+	}
+}
+
+// WithConcurrency sets the number of notion pages that are created, updated or archived in parallel
+func WithConcurrency(concurrency int) Option {
+	return func(s *Syncer) {
+		s.concurrency = concurrency
+	}
+}
+
+// WithNotionRateLimit sets the average number of requests per second made to the notion api.
+// A value of zero disables the rate limit.
+func WithNotionRateLimit(rps float64) Option {
+	return func(s *Syncer) {
+		s.notionRPS = rps
+	}
+}
+
+// WithNotionLimiter makes the syncer wait for the given limiter before each request made to the notion api,
+// instead of creating one from the rate limit. It allows to share the limiter with the requests made outside
+// of the syncer, like the retries of the http transport.
+func WithNotionLimiter(limiter *rate.Limiter) Option {
+	return func(s *Syncer) {
+		s.notionLimiter = limiter
+	}
+}
+
+// WithGitHubUser syncs the stars of the given github user, instead of the stars of the authenticated user
+func WithGitHubUser(username string) Option {
+	return func(s *Syncer) {
+		s.githubUser = username
+	}
+}
+
+// WithGitHubAccount adds another github account whose stars are synced to the same notion database.
+// The stars of the given username are fetched with the given client, or the stars of the authenticated user when the
+// username is empty. When several accounts are synced, the accounts that starred each repo are stored in the
+// "starred by" property, and a page is only archived once none of the accounts stars its repo.
+func WithGitHubAccount(client *github.Client, username string) Option {
+	return func(s *Syncer) {
+		s.accounts = append(s.accounts, gitHubAccount{
+			client: client,
+			user:   username,
+		})
+	}
+}
+
+// WithStateStore sets the store used to persist information between syncs, which enables incremental syncs
+func WithStateStore(store *state.Store) Option {
+	return func(s *Syncer) {
+		s.state = store
+	}
+}
+
+// WithFullSyncInterval sets how often a full sync runs. Between full syncs, only the repos starred since
+// the last sync are fetched, and unstarred repos are not detected. A value of zero disables incremental syncs.
+func WithFullSyncInterval(interval time.Duration) Option {
+	return func(s *Syncer) {
+		s.fullSyncInterval = interval
+	}
+}
+
+// WithStarLists syncs the star lists of each repo to a multi-select property of the notion database
+func WithStarLists() Option {
+	return func(s *Syncer) {
+		s.starLists = true
+	}
+}
+
+// WithListsDatabase syncs the star lists of each repo to a relation property, whose pages are stored in
+// the given notion database. Missing lists are created in that database.
+func WithListsDatabase(databaseID string) Option {
+	return func(s *Syncer) {
+		s.starLists = true
+		s.listsDatabaseID = notionapi.DatabaseID(databaseID)
+	}
+}
+
+// WithReadme renders the README of each repo as the body of its notion page, when the page is created
+func WithReadme() Option {
+	return func(s *Syncer) {
+		s.readme = true
+	}
+}
+
+// WithUnstarPolicy sets what a full sync does to the pages of the repos that are not starred anymore.
+// By default, the pages are archived.
+func WithUnstarPolicy(policy UnstarPolicy) Option {
+	return func(s *Syncer) {
+		s.unstarPolicy = policy
+	}
+}
+
+// WithArchiveDatabase sets the notion database where the pages of unstarred repos are moved, when the unstar
+// policy is UnstarMove
+func WithArchiveDatabase(databaseID string) Option {
+	return func(s *Syncer) {
+		s.archiveDatabaseID = notionapi.DatabaseID(databaseID)
+	}
+}
+
+// WithDeleteLimit refuses to apply the plan of a sync that removes more pages than the given limit allows.
+// By default, there is no limit.
+func WithDeleteLimit(limit DeleteLimit) Option {
+	return func(s *Syncer) {
+		s.deleteLimit = &limit
+	}
+}
@@ -0,0 +1,369 @@
+package syncer
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jomei/notionapi"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/log"
+	"github.com/brpaz/github-stars-notion-sync/internal/state"
+)
+
+// ChangeAction is the kind of change that a sync applies to a notion page
+type ChangeAction string
+
+const (
+	ActionCreate  ChangeAction = "create"
+	ActionUpdate  ChangeAction = "update"
+	ActionArchive ChangeAction = "archive"
+	// ActionMark marks the page of an unstarred repo as unstarred, instead of archiving it
+	ActionMark ChangeAction = "mark"
+	// ActionMove moves the page of an unstarred repo to the archive database, instead of archiving it
+	ActionMove ChangeAction = "move"
+	// ActionRestore unarchives the page of a repo that was starred again, instead of creating a new one
+	ActionRestore ChangeAction = "restore"
+	// ActionAdopt writes the repository id to an existing page that holds the repo without it, instead of creating a new one
+	ActionAdopt ChangeAction = "adopt"
+)
+
+// unstars tells if the action is applied to the page of an unstarred repo
+func (a ChangeAction) unstars() bool {
+	return a == ActionArchive || a == ActionMark || a == ActionMove
+}
+
+// Change represents a single change that a sync will apply to the notion database
+type Change struct {
+	Action ChangeAction `json:"action"`
+	RepoID int64        `json:"repository_id"`
+	Name   string       `json:"name"`
+	URL    string       `json:"url,omitempty"`
+	PageID string       `json:"page_id,omitempty"`
+	// Properties holds the names of the properties that will be changed by an update
+	Properties []string `json:"properties,omitempty"`
+
+	repo    *starredRepo
+	page    *notionPage
+	request *notionapi.PageUpdateRequest
+	// archived is recorded in the state store when the change has no repo
+	archived bool
+	// copyID is the copy of the page in the archive database, made by a move or removed by a restore
+	copyID string
+}
+
+// syncedRepo holds the information of a synced repo that is recorded in the state store
+type syncedRepo struct {
+	repoID    int64
+	name      string
+	pageID    string
+	unstarred bool
+	archived  bool
+	copyID    string
+}
+
+// Plan holds all the changes that a sync will apply to the notion database.
+// It allows to inspect what a sync is going to do before any change is made.
+type Plan struct {
+	DatabaseID string   `json:"database_id"`
+	Changes    []Change `json:"changes"`
+	// Skipped is the number of pages that are left untouched, because they are already up to date or protected
+	Skipped int `json:"skipped"`
+	// Full tells if the plan reconciles all the starred repos. Incremental plans only include the repos
+	// starred since the last sync, and never archive pages.
+	Full bool `json:"full"`
+	// Duplicates holds the repos that have several pages in the notion database. Only the first page of each
+	// repo is synced, until the duplicates are merged with the dedupe command.
+	Duplicates []DuplicatePages `json:"duplicates,omitempty"`
+	// Malformed holds the pages that can't be matched with a repo, which are left untouched
+	Malformed []MalformedPage `json:"malformed,omitempty"`
+
+	startedAt time.Time
+	// cursorScope is the key of the cursor of the synced database and accounts in the state store
+	cursorScope string
+	// fields holds the optional fields whose property exists in the database
+	fields optionalFields
+	// existingPages is the number of pages of the database that hold a repo
+	existingPages int
+	// starredRepos is the number of starred repos fetched from github
+	starredRepos int
+	// lists holds the pages of the lists database, when the star lists are synced to a relation property
+	lists *listsDatabase
+	// archive holds the schema of the archive database, when pages are moved to it
+	archive *archiveDatabase
+	// unrecorded holds the repos whose pages are up to date, but that are not yet recorded in the state store
+	unrecorded []syncedRepo
+}
+
+// Count returns the number of changes in the plan with the given action
+func (p *Plan) Count(action ChangeAction) int {
+	count := 0
+	for _, change := range p.Changes {
+		if change.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// buildPlan compares the notion pages with the starred repos and returns the changes required to sync them.
+// Unless fullSync is set, the starred repos are only the ones starred recently, so no page is archived.
+// The records of previous syncs, when available, allow to detect renamed, unstarred and starred again repos. When star lists are synced, lists holds the lists of every starred repo.
+// With incremental syncs, stats holds the statistics of the repos starred before the last sync.
+// The optional properties are only compared when the database has them.
+func (s *Syncer) buildPlan(ctx context.Context, databaseID notionapi.DatabaseID, notionPages *databasePages, starredRepos *starredRepoCollection, fullSync bool, records map[int64]state.RepoRecord, lists starLists, stats map[int64]repoStats, fields optionalFields) *Plan {
+	plan := &Plan{
+		DatabaseID: databaseID.String(),
+		Full:       fullSync,
+		fields:     fields,
+	}
+
+	creates := make([]Change, 0)
+	updates := make([]Change, 0)
+	archives := make([]Change, 0)
+
+	// find the pages that need to be created (i.e. starred repos that are not in the notion database)
+	// or updated (i.e. starred repos whose metadata changed since the page was created)
+	for i := range starredRepos.Repos {
+		repo := &starredRepos.Repos[i]
+		page, ok := notionPages.GetByRepo(repo.ID)
+
+		// incremental syncs only fetch the recent stars of each account, so the accounts that starred the repo
+		// before are kept. They are only removed by full syncs.
+		if ok && !fullSync && repo.StarredBy != nil {
+			repo.StarredBy = mergeOptions(page.StarredBy, repo.StarredBy)
+		}
+
+		// for the same reason, the first star of the repo may only be known by its page
+		if ok && !fullSync && !page.StarredAt.IsZero() && page.StarredAt.Before(repo.StarredAt) {
+			repo.StarredAt = page.StarredAt
+		}
+
+		record, hasRecord := records[repo.ID]
+		if hasRecord && record.Unstarred {
+			log.Info(ctx, "repository was starred again", log.String("repo", repo.Name))
+		}
+
+		if hasRecord && record.Name != repo.Name {
+			log.Info(ctx, "repository was renamed", log.String("from", record.Name), log.String("to", repo.Name))
+		}
+
+		if ok && page.Protected {
+			plan.Skipped++
+			continue
+		}
+
+		// protected pages are never updated, so the warning is only logged for the other pages, that record the
+		// archived state and don't repeat it on every sync
+		if archivedUpstream(repo, page, record, hasRecord, fields) {
+			log.Warn(ctx, "repository was archived upstream", log.String("repo", repo.Name), log.String("url", repo.URL))
+		}
+
+		// the page of a repo that was starred again was archived by a previous sync, so it is restored
+		// instead of creating a new one
+		if !ok && hasRecord && record.Unstarred && record.PageID != "" {
+			creates = append(creates, Change{
+				Action: ActionRestore,
+				RepoID: repo.ID,
+				Name:   repo.Name,
+				URL:    repo.URL,
+				PageID: record.PageID,
+				repo:   repo,
+				copyID: record.CopyID,
+			})
+			continue
+		}
+
+		if !ok {
+			// pages added before the sync was used, like a database curated by hand, hold the url of the repo
+			// but not its id, so they are adopted instead of creating a duplicate
+			if page, adopted := notionPages.Adopt(repo); adopted {
+				log.Info(ctx, "adopting existing page of repository", log.String("repo", repo.Name), log.String("page", page.ID))
+
+				if page.Protected {
+					plan.Skipped++
+					continue
+				}
+
+				request := buildUpdatePageRequestFromRepo(page, repo, s.mapping, fields)
+				if request == nil {
+					request = &notionapi.PageUpdateRequest{Properties: notionapi.Properties{}}
+				}
+
+				request.Properties[s.mapping.Name(FieldRepoID)] = &notionapi.NumberProperty{Number: float64(repo.ID)}
+
+				updates = append(updates, Change{
+					Action:     ActionAdopt,
+					RepoID:     repo.ID,
+					Name:       repo.Name,
+					URL:        repo.URL,
+					PageID:     page.ID,
+					Properties: changeNames(request),
+					repo:       repo,
+					page:       page,
+					request:    request,
+				})
+				continue
+			}
+
+			creates = append(creates, Change{
+				Action: ActionCreate,
+				RepoID: repo.ID,
+				Name:   repo.Name,
+				URL:    repo.URL,
+				repo:   repo,
+			})
+			continue
+		}
+
+		// the page is always compared with the repo, even if the repo didn't change since the last sync,
+		// so that the values edited by hand in notion are repaired
+		if request := buildUpdatePageRequestFromRepo(page, repo, s.mapping, fields); request != nil {
+			updates = append(updates, Change{
+				Action:     ActionUpdate,
+				RepoID:     repo.ID,
+				Name:       repo.Name,
+				URL:        repo.URL,
+				PageID:     page.ID,
+				Properties: changeNames(request),
+				repo:       repo,
+				page:       page,
+				request:    request,
+			})
+			continue
+		}
+
+		plan.Skipped++
+		plan.unrecorded = append(plan.unrecorded, syncedRepo{
+			repoID:   repo.ID,
+			name:     repo.Name,
+			pageID:   page.ID,
+			archived: repo.Lifecycle.Archived,
+		})
+	}
+
+	// incremental syncs don't fetch the repos starred before the last sync, so the lists and the statistics of their
+	// pages are compared with the star lists and the statistics fetched separately, to keep them up to date
+	if !fullSync {
+		for i := range notionPages.Pages {
+			page := &notionPages.Pages[i]
+			if page.Protected || starredRepos.Contains(page.GitHubID) {
+				continue
+			}
+
+			properties := notionapi.Properties{}
+			if lists != nil && !sameOptions(page.Lists, lists.Of(page.GitHubID)) {
+				properties[s.mapping.Name(FieldLists)] = buildMultiSelectProperty(lists.Of(page.GitHubID))
+			}
+
+			if repoStats, ok := stats[page.GitHubID]; ok {
+				maps.Copy(properties, buildStatsProperties(page, repoStats, s.mapping, fields))
+			}
+
+			if len(properties) == 0 {
+				continue
+			}
+
+			request := &notionapi.PageUpdateRequest{Properties: properties}
+
+			updates = append(updates, Change{
+				Action:     ActionUpdate,
+				RepoID:     page.GitHubID,
+				Name:       page.Title,
+				URL:        page.URL,
+				PageID:     page.ID,
+				Properties: propertyNames(request.Properties),
+				page:       page,
+				request:    request,
+				archived:   records[page.GitHubID].Archived,
+			})
+		}
+	}
+
+	// find the pages that need to be archived (i.e. notion pages whose repo is not starred anymore by any account).
+	// What is done to those pages depends on the unstar policy, and protected pages are never touched.
+	for i := range notionPages.Pages {
+		page := &notionPages.Pages[i]
+
+		if fullSync && !starredRepos.Contains(page.GitHubID) {
+			log.Info(ctx, "repository was unstarred", log.String("repo", page.Title))
+
+			// pages that the sync can't identify as its own, like rows added by hand, are never removed
+			if !page.createdBySync() {
+				log.Info(ctx, "page was not created by the sync, leaving it untouched", log.String("repo", page.Title))
+				plan.Skipped++
+				continue
+			}
+
+			if page.Protected {
+				plan.Skipped++
+				continue
+			}
+
+			if change, ok := s.unstarChange(page); ok {
+				archives = append(archives, change)
+			}
+		}
+	}
+
+	plan.Changes = make([]Change, 0, len(creates)+len(updates)+len(archives))
+	plan.Changes = append(plan.Changes, creates...)
+	plan.Changes = append(plan.Changes, updates...)
+	plan.Changes = append(plan.Changes, archives...)
+
+	return plan
+}
+
+// archivedUpstream tells if the repo was archived on github since it was last synced. The previous state is read from
+// its page, when the database stores it, or else from the record of the previous sync.
+func archivedUpstream(repo *starredRepo, page *notionPage, record state.RepoRecord, hasRecord bool, fields optionalFields) bool {
+	if !repo.Lifecycle.Archived {
+		return false
+	}
+
+	if page != nil && fields.Has(FieldArchived) {
+		return !page.Lifecycle.Archived
+	}
+
+	return hasRecord && !record.Archived && !record.Unstarred
+}
+
+// mergeOptions returns the sorted union of two lists of option names
+func mergeOptions(a, b []string) []string {
+	merged := slices.Clone(a)
+	for _, name := range b {
+		if !slices.Contains(merged, name) {
+			merged = append(merged, name)
+		}
+	}
+
+	slices.Sort(merged)
+
+	return merged
+}
+
+// changeNames returns the sorted names of the properties changed by the request, followed by "icon" when it
+// changes the icon of the page
+func changeNames(request *notionapi.PageUpdateRequest) []string {
+	names := propertyNames(request.Properties)
+	if request.Icon != nil {
+		names = append(names, "icon")
+	}
+
+	return names
+}
+
+// propertyNames returns the sorted names of the given properties
+func propertyNames(properties notionapi.Properties) []string {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,69 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/jomei/notionapi"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/log"
+	"github.com/brpaz/github-stars-notion-sync/internal/markdown"
+)
+
+// fetchReadmeBlocks fetches the README of the repo and converts it to notion blocks.
+// It returns no blocks when the repo has no README.
+func (s *Syncer) fetchReadmeBlocks(ctx context.Context, repo *starredRepo) ([]notionapi.Block, error) {
+	readme, resp, err := s.github.Repositories.GetReadme(ctx, repo.Owner, repo.Name, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return []notionapi.Block{}, nil
+		}
+
+		return nil, fmt.Errorf("error fetching the readme of %s/%s: %w", repo.Owner, repo.Name, err)
+	}
+
+	content, err := readme.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("error decoding the readme of %s/%s: %w", repo.Owner, repo.Name, err)
+	}
+
+	// relative links point to the files of the repo on github, and relative images to their raw content,
+	// since notion can only display images from absolute urls
+	converter := markdown.Converter{}
+	if linkBaseURL, err := url.Parse(readme.GetHTMLURL()); err == nil && readme.GetHTMLURL() != "" {
+		converter.LinkBaseURL = linkBaseURL
+	}
+
+	if imageBaseURL, err := url.Parse(readme.GetDownloadURL()); err == nil && readme.GetDownloadURL() != "" {
+		converter.ImageBaseURL = imageBaseURL
+	}
+
+	converter.OnTruncate = func(blockType notionapi.BlockType, dropped int) {
+		log.Warn(ctx, "readme blocks don't fit in the page and are dropped", log.String("repo", repo.Name), log.String("block", string(blockType)), log.Int("dropped", dropped))
+	}
+
+	return converter.Convert([]byte(content)), nil
+}
+
+// appendPageBlocks appends the blocks to the body of the page, in batches of the maximum size accepted by notion
+func (s *Syncer) appendPageBlocks(ctx context.Context, pageID notionapi.ObjectID, blocks []notionapi.Block) error {
+	for len(blocks) > 0 {
+		batch := blocks[:min(len(blocks), markdown.MaxBlocksPerRequest)]
+		blocks = blocks[len(batch):]
+
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		_, err := s.notion.Block.AppendChildren(ctx, notionapi.BlockID(pageID), &notionapi.AppendBlockChildrenRequest{
+			Children: batch,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jomei/notionapi"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/log"
+)
+
+// restoreNotionPage unarchives the page of a repo that was starred again and refreshes its properties, so that the
+// notes added to the page are kept. When the page can't be restored, because it was deleted from the trash or it
+// belongs to another database, a new page is created instead, and the change is reported as a create.
+// The copy left in the archive database by a move is archived, so that the repo has a single page.
+func (s *Syncer) restoreNotionPage(ctx context.Context, plan *Plan, change *Change) (string, error) {
+	pageID, err := s.restoreArchivedPage(ctx, plan, change)
+	if err != nil {
+		return "", err
+	}
+
+	if change.copyID != "" {
+		// the page is already restored, so the copy is only reported instead of failing the change, which would
+		// restore the page again on the next sync
+		if err := s.deleteNotionPage(ctx, notionapi.PageID(change.copyID)); err != nil && !isNotionNotFound(err) {
+			log.Error(ctx, "error archiving the copy of the page in the archive database, remove it by hand",
+				log.String("repo", change.Name),
+				log.String("copy", change.copyID),
+				log.String("error", err.Error()),
+			)
+		}
+
+		change.copyID = ""
+	}
+
+	return pageID, nil
+}
+
+// restoreArchivedPage restores the archived page of the change, or creates a new one when it can't be restored
+func (s *Syncer) restoreArchivedPage(ctx context.Context, plan *Plan, change *Change) (string, error) {
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	page, err := s.notion.Page.Get(ctx, notionapi.PageID(change.PageID))
+	if err != nil {
+		if !isNotionNotFound(err) {
+			return "", err
+		}
+
+		log.Info(ctx, "archived page no longer exists, creating a new one", log.String("repo", change.Name))
+		change.Action = ActionCreate
+
+		return s.createNotionPage(ctx, plan, change.repo)
+	}
+
+	if !sameNotionID(page.Parent.DatabaseID.String(), plan.DatabaseID) {
+		log.Info(ctx, "archived page belongs to another database, creating a new one", log.String("repo", change.Name))
+		change.Action = ActionCreate
+
+		return s.createNotionPage(ctx, plan, change.repo)
+	}
+
+	// notion doesn't allow to edit the properties of an archived page, so it is unarchived first
+	if page.Archived {
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return "", err
+		}
+
+		_, err := s.notion.Page.Update(ctx, notionapi.PageID(change.PageID), &notionapi.PageUpdateRequest{
+			Properties: notionapi.Properties{},
+			Archived:   false,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// a page whose properties were cleared gets all the properties of the repo again
+	request := &notionapi.PageUpdateRequest{Properties: buildPagePropertiesFromRepo(change.repo, s.mapping, plan.fields)}
+	if restored, err := parseNotionPage(*page, s.mapping); err == nil {
+		request = buildUpdatePageRequestFromRepo(&restored, change.repo, s.mapping, plan.fields)
+	}
+
+	if request != nil {
+		if err := s.updateNotionPage(ctx, plan, notionapi.PageID(change.PageID), request); err != nil {
+			return "", err
+		}
+	}
+
+	return change.PageID, nil
+}
+
+// isNotionNotFound tells if the error returned by the notion api is caused by a missing object
+func isNotionNotFound(err error) bool {
+	var notionErr *notionapi.Error
+
+	return errors.As(err, &notionErr) && notionErr.Status == http.StatusNotFound
+}
+
+// isNotionValidationError tells if the error returned by the notion api is caused by an invalid request
+func isNotionValidationError(err error) bool {
+	var notionErr *notionapi.Error
+
+	return errors.As(err, &notionErr) && notionErr.Status == http.StatusBadRequest
+}
+
+// sameNotionID tells if two notion ids are the same, since they can be written with or without dashes
+func sameNotionID(a, b string) bool {
+	return strings.ReplaceAll(a, "-", "") == strings.ReplaceAll(b, "-", "")
+}
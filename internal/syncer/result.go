@@ -0,0 +1,79 @@
+package syncer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// SyncResult summarizes the changes applied to the notion database by a sync
+type SyncResult struct {
+	Created  int
+	Updated  int
+	Archived int
+	// Marked is the number of pages of unstarred repos that were marked as unstarred
+	Marked int
+	// Moved is the number of pages of unstarred repos that were moved to the archive database
+	Moved int
+	// Restored is the number of archived pages that were restored, because their repo was starred again
+	Restored int
+	// Adopted is the number of existing pages without a repository id that were matched with a starred repo
+	Adopted int
+	// Skipped is the number of items that were left untouched, for example, because they were already up to date
+	Skipped int
+	Failed  int
+	// Duplicates is the number of repos that have several pages in the notion database
+	Duplicates int
+	// Malformed holds the pages of the notion database that can't be matched with a repo, and were left untouched
+	Malformed []MalformedPage
+	// Errors holds the errors of all the failed items, joined with errors.Join
+	Errors error
+
+	mu sync.Mutex
+}
+
+// Succeeded returns the number of items that were successfully changed
+func (r *SyncResult) Succeeded() int {
+	return r.Created + r.Updated + r.Archived + r.Marked + r.Moved + r.Restored + r.Adopted
+}
+
+// recordSuccess increments the counter of the given action
+func (r *SyncResult) recordSuccess(action ChangeAction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch action {
+	case ActionCreate:
+		r.Created++
+	case ActionUpdate:
+		r.Updated++
+	case ActionArchive:
+		r.Archived++
+	case ActionMark:
+		r.Marked++
+	case ActionMove:
+		r.Moved++
+	case ActionRestore:
+		r.Restored++
+	case ActionAdopt:
+		r.Adopted++
+	}
+}
+
+// recordFailure increments the failed counter and keeps the error of the given change
+func (r *SyncResult) recordFailure(change *Change, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Failed++
+	r.Errors = errors.Join(r.Errors, fmt.Errorf("error applying %s to %s: %w", change.Action, change.Name, err))
+}
+
+// err returns an error describing the failed items, or nil if all the items were applied
+func (r *SyncResult) err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%d of %d changes failed: %w", r.Failed, r.Failed+r.Succeeded(), r.Errors)
+}
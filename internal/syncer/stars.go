@@ -11,13 +11,67 @@ type starredRepoCollection struct {
 
 // starredRepo holds essential information about a starred repository. This is the information that will be synced to notion and avoid using the raw github.Repository struct, which contains a lot of information that is not needed
 type starredRepo struct {
-	ID          int64
-	Name        string
-	Description string
-	Language    string
-	Topics      []string
-	URL         string
-	StarredAt   time.Time
+	ID    int64
+	Name  string
+	Owner string
+	// OwnerType is either "User" or "Organization"
+	OwnerType      string
+	OwnerAvatarURL string
+	Description    string
+	Language       string
+	Topics         []string
+	URL            string
+	StarredAt      time.Time
+	// Lists holds the names of the star lists that include the repo. It is nil when star lists are not synced.
+	Lists []string
+	// StarredBy holds the names of the accounts that starred the repo. It is nil when a single account is synced.
+	StarredBy []string
+	Stats     repoStats
+	Lifecycle repoLifecycle
+}
+
+// repoStats holds the statistics of a repo, which change between syncs
+type repoStats struct {
+	Stars      int
+	Forks      int
+	OpenIssues int
+}
+
+// byField returns the statistics by the field of the property that stores them
+func (s repoStats) byField() map[Field]int {
+	return map[Field]int{
+		FieldStars:      s.Stars,
+		FieldForks:      s.Forks,
+		FieldOpenIssues: s.OpenIssues,
+	}
+}
+
+// repoLifecycle holds the information of a repo that tells if it is maintained
+type repoLifecycle struct {
+	Archived  bool
+	Fork      bool
+	Template  bool
+	PushedAt  time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// checkboxes returns the flags of the lifecycle by the field of the property that stores them
+func (l repoLifecycle) checkboxes() map[Field]bool {
+	return map[Field]bool{
+		FieldArchived: l.Archived,
+		FieldFork:     l.Fork,
+		FieldTemplate: l.Template,
+	}
+}
+
+// dates returns the dates of the lifecycle by the field of the property that stores them
+func (l repoLifecycle) dates() map[Field]time.Time {
+	return map[Field]time.Time{
+		FieldPushedAt:      l.PushedAt,
+		FieldRepoCreatedAt: l.CreatedAt,
+		FieldRepoUpdatedAt: l.UpdatedAt,
+	}
 }
 
 // newStarredRepoCollection creates a new instance starredRepoCollection
@@ -34,6 +88,17 @@ func (c *starredRepoCollection) Add(repo starredRepo) {
 	c.TotalCount++
 }
 
+// Get returns the starred repo with the given id, if it exists in the collection
+func (c *starredRepoCollection) Get(repoID int64) (*starredRepo, bool) {
+	for i := range c.Repos {
+		if c.Repos[i].ID == repoID {
+			return &c.Repos[i], true
+		}
+	}
+
+	return nil, false
+}
+
 // Contains checks if a repository already exists in the collection (by ID, not by name, because a user can have multiple repositories with the same name (but different IDs)
 func (c *starredRepoCollection) Contains(repoID int64) bool {
 	for _, repo := range c.Repos {
@@ -0,0 +1,123 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// repoStatsBatchSize is the number of repos whose statistics are fetched by a single graphql query
+const repoStatsBatchSize = 50
+
+// repoStatsFields are the fields of a repo fetched by the statistics query. The open issues of the rest api,
+// which are stored by full syncs, include the open pull requests.
+const repoStatsFields = `databaseId stargazerCount forkCount issues(states: OPEN) { totalCount } pullRequests(states: OPEN) { totalCount }`
+
+type graphQLCount struct {
+	TotalCount int `json:"totalCount"`
+}
+
+type repoStatsNode struct {
+	DatabaseID     int64        `json:"databaseId"`
+	StargazerCount int          `json:"stargazerCount"`
+	ForkCount      int          `json:"forkCount"`
+	Issues         graphQLCount `json:"issues"`
+	PullRequests   graphQLCount `json:"pullRequests"`
+}
+
+// hasStats tells if the notion database has any of the properties that store the statistics of the repos
+func (f optionalFields) hasStats() bool {
+	for field := range (repoStats{}).byField() {
+		if f.Has(field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fetchGitHubRepoStats returns the statistics of the repos of the given pages, indexed by repo id.
+// The repos are looked up by the owner and name in the url of their page, so the pages without a github url
+// and the repos that no longer exist are left out.
+func (s *Syncer) fetchGitHubRepoStats(ctx context.Context, pages []*notionPage) (map[int64]repoStats, error) {
+	stats := make(map[int64]repoStats, len(pages))
+
+	for start := 0; start < len(pages); start += repoStatsBatchSize {
+		batch := pages[start:min(len(pages), start+repoStatsBatchSize)]
+
+		// each repo is aliased by its position in the batch, since a query can't repeat the same field
+		var definitions, fields []string
+		variables := make(map[string]any, 2*len(batch))
+		for i, page := range batch {
+			owner, name, ok := repoFullName(page.URL)
+			if !ok {
+				continue
+			}
+
+			definitions = append(definitions, fmt.Sprintf("$owner%d: String!, $name%d: String!", i, i))
+			fields = append(fields, fmt.Sprintf("r%d: repository(owner: $owner%d, name: $name%d) { %s }", i, i, i, repoStatsFields))
+			variables[fmt.Sprintf("owner%d", i)] = owner
+			variables[fmt.Sprintf("name%d", i)] = name
+		}
+
+		if len(fields) == 0 {
+			continue
+		}
+
+		query := fmt.Sprintf("query(%s) {\n  %s\n}", strings.Join(definitions, ", "), strings.Join(fields, "\n  "))
+
+		// deleted repos are returned as null, along with a not found error
+		var resp map[string]*repoStatsNode
+		var graphQLErr graphQLErrors
+		if err := s.githubGraphQL(ctx, query, variables, &resp); err != nil && !(errors.As(err, &graphQLErr) && graphQLErr.notFound()) {
+			return nil, err
+		}
+
+		for _, node := range resp {
+			if node == nil {
+				continue
+			}
+
+			stats[node.DatabaseID] = repoStats{
+				Stars:      node.StargazerCount,
+				Forks:      node.ForkCount,
+				OpenIssues: node.Issues.TotalCount + node.PullRequests.TotalCount,
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// repoFullName returns the owner and the name of the repo from its github url
+func repoFullName(repoURL string) (string, string, bool) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil || parsed.Host != "github.com" {
+		return "", "", false
+	}
+
+	owner, name, ok := strings.Cut(strings.Trim(parsed.Path, "/"), "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", false
+	}
+
+	return owner, name, true
+}
+
+// unfetchedPages returns the pages of the repos that an incremental sync didn't fetch, because they were starred
+// before the last sync. The protected pages and the pages marked as unstarred are left out, since they are not refreshed.
+func unfetchedPages(notionPages *databasePages, starredRepos *starredRepoCollection) []*notionPage {
+	pages := make([]*notionPage, 0, len(notionPages.Pages))
+	for i := range notionPages.Pages {
+		page := &notionPages.Pages[i]
+		if page.Protected || page.Unstarred || starredRepos.Contains(page.GitHubID) {
+			continue
+		}
+
+		pages = append(pages, page)
+	}
+
+	return pages
+}
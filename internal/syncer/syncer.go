@@ -4,30 +4,72 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v57/github"
 	"github.com/jomei/notionapi"
+	"golang.org/x/time/rate"
 
 	"github.com/brpaz/github-stars-notion-sync/internal/log"
+	"github.com/brpaz/github-stars-notion-sync/internal/markdown"
+	"github.com/brpaz/github-stars-notion-sync/internal/state"
 )
 
 var (
-	ErrNilGithubClient = errors.New("github client cannot be nil")
-	ErrNilNotionClient = errors.New("notion client cannot be nil")
+	ErrNilGithubClient    = errors.New("github client cannot be nil")
+	ErrNilNotionClient    = errors.New("notion client cannot be nil")
+	ErrInvalidConcurrency = errors.New("concurrency must be greater than zero")
+	ErrInvalidNotionRPS   = errors.New("notion requests per second cannot be negative")
 )
 
 const (
 	githubReposPerPage = 100
 	notionPagesPerPage = 50
+
+	// notion api allows an average of 3 requests per second. See https://developers.notion.com/reference/request-limits
+	DefaultNotionRPS   = 3
+	DefaultConcurrency = 3
+
+	DefaultFullSyncInterval = 24 * time.Hour
 )
 
 type Syncer struct {
 	github *github.Client
-	notion *notionapi.Client
+	// githubUser is the user whose stars are synced. When empty, the stars of the authenticated user are synced.
+	githubUser string
+	// accounts holds the additional github accounts whose stars are synced to the same notion database
+	accounts    []gitHubAccount
+	notion      *notionapi.Client
+	mapping     PropertyMapping
+	concurrency int
+	notionRPS   float64
+	// notionLimiter is shared by all the requests made to the notion api, to avoid hitting its rate limits
+	notionLimiter *rate.Limiter
+	// state persists information between syncs. When nil, every sync is a full sync.
+	state            *state.Store
+	fullSyncInterval time.Duration
+	// starLists enables the sync of the star lists of each repo. When listsDatabaseID is set, the lists are
+	// stored as a relation to the pages of that database, instead of a multi-select.
+	starLists       bool
+	listsDatabaseID notionapi.DatabaseID
+	// readme enables the conversion of the README of each repo to the body of its created page
+	readme bool
+	// unstarPolicy is what a full sync does to the pages of unstarred repos. When it is UnstarMove, the pages
+	// are moved to the archiveDatabaseID database.
+	unstarPolicy      UnstarPolicy
+	archiveDatabaseID notionapi.DatabaseID
+	// deleteLimit is the maximum number of pages that a sync can remove. When nil, there is no limit.
+	deleteLimit *DeleteLimit
 }
 
 // New creates a new Syncer instance with the given github and notion clients
-func New(githubClient *github.Client, notionClient *notionapi.Client) (*Syncer, error) {
+func New(githubClient *github.Client, notionClient *notionapi.Client, opts ...Option) (*Syncer, error) {
 	if githubClient == nil {
 		return nil, ErrNilGithubClient
 	}
@@ -36,92 +78,545 @@ func New(githubClient *github.Client, notionClient *notionapi.Client) (*Syncer,
 		return nil, ErrNilNotionClient
 	}
 
-	return &Syncer{
-		github: githubClient,
-		notion: notionClient,
-	}, nil
+	s := &Syncer{
+		github:      githubClient,
+		notion:      notionClient,
+		mapping:     DefaultPropertyMapping(),
+		concurrency: DefaultConcurrency,
+		notionRPS:   DefaultNotionRPS,
+
+		fullSyncInterval: DefaultFullSyncInterval,
+		unstarPolicy:     UnstarArchive,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for _, account := range s.accounts {
+		if account.client == nil {
+			return nil, ErrNilGithubClient
+		}
+	}
+
+	if err := s.mapping.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid property mapping: %w", err)
+	}
+
+	if s.concurrency < 1 {
+		return nil, ErrInvalidConcurrency
+	}
+
+	if s.notionRPS < 0 {
+		return nil, ErrInvalidNotionRPS
+	}
+
+	if err := s.unstarPolicy.Validate(); err != nil {
+		return nil, err
+	}
+
+	if s.unstarPolicy == UnstarMove && s.archiveDatabaseID == "" {
+		return nil, ErrArchiveDatabaseRequired
+	}
+
+	if s.notionLimiter == nil {
+		s.notionLimiter = NewNotionLimiter(s.notionRPS)
+	}
+
+	return s, nil
 }
 
 // SyncStars syncs the github stars with the notion database
 // This method will:
 // 1. Get all the starred repos from github
 // 2. Get all the pages from the notion database
-// 3. Compare the two lists and create/delete the notion pages accordingly
-func (s *Syncer) SyncStars(ctx context.Context, notionDatabaseID string) error {
+// 3. Compare the two lists and create/update/delete the notion pages accordingly
+//
+// The returned result summarizes the applied changes. When some of the changes fail, the result is returned
+// together with an error that joins the errors of every failed change. When the plan removes more pages than the
+// delete limit allows, no change is applied and a *DeleteLimitError is returned.
+func (s *Syncer) SyncStars(ctx context.Context, notionDatabaseID string) (*SyncResult, error) {
+	plan, err := s.Plan(ctx, notionDatabaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkDeleteLimit(plan); err != nil {
+		log.Error(ctx, "refusing to apply the sync", log.String("error", err.Error()))
+		return nil, err
+	}
+
+	result := s.applyPlan(ctx, plan)
+	result.Duplicates = len(plan.Duplicates)
+	result.Malformed = plan.Malformed
+	s.recordSyncedRepos(ctx, plan.unrecorded)
+
+	// the cursor is only moved forward when all the changes were applied, so that failed items are retried
+	// by the next incremental sync
+	if result.Failed == 0 {
+		if err := s.saveCursor(plan); err != nil {
+			log.Error(ctx, "error saving sync state", log.String("error", err.Error()))
+		}
+	}
+
+	log.Info(ctx, "sync finished",
+		log.Int("created", result.Created),
+		log.Int("updated", result.Updated),
+		log.Int("archived", result.Archived),
+		log.Int("marked", result.Marked),
+		log.Int("moved", result.Moved),
+		log.Int("restored", result.Restored),
+		log.Int("adopted", result.Adopted),
+		log.Int("skipped", result.Skipped),
+		log.Int("failed", result.Failed),
+		log.Int("malformed", len(result.Malformed)),
+	)
+
+	if err := result.err(); err != nil {
+		return result, fmt.Errorf("error syncing notion database: %w", err)
+	}
+
+	return result, nil
+}
+
+// Plan fetches the starred repos and the notion pages and computes the changes required to sync them,
+// without making any change to the notion database.
+func (s *Syncer) Plan(ctx context.Context, notionDatabaseID string) (*Plan, error) {
 	log.Info(ctx, "starting syncer")
 
+	startedAt := time.Now()
+
 	databaseID := notionapi.DatabaseID(notionDatabaseID)
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
 	notionDatabase, err := s.notion.Database.Get(ctx, databaseID)
 	if err != nil {
-		return fmt.Errorf("error getting notion database: %w", err)
+		return nil, fmt.Errorf("error getting notion database: %w", err)
 	}
 
 	// ensure that the notion database has the required fields.
 	// this is critical to ensure that the syncer works as expected.
 	if err := s.validateDatabaseFields(notionDatabase); err != nil {
-		return fmt.Errorf("error validating notion database: %w", err)
+		return nil, fmt.Errorf("error validating notion database: %w", err)
+	}
+
+	fields := s.optionalFields(ctx, notionDatabase)
+
+	// the id returned by notion is used, since the one given by the user may be formatted without dashes
+	cursorScope, err := s.cursorScope(ctx, notionDatabase.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("error getting github user: %w", err)
+	}
+
+	starredSince, err := s.starredSince(cursorScope, startedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sync state: %w", err)
+	}
+
+	var listsDB *listsDatabase
+	if s.listsDatabaseID != "" {
+		listsDB, err = s.loadListsDatabase(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting notion lists database: %w", err)
+		}
 	}
 
 	log.Info(ctx, "fetching pages from notion database. Depending on the size of the database, this might take a while.")
 	notionPages, err := s.getPagesFromNotionDatabase(ctx, databaseID)
 	if err != nil {
-		return fmt.Errorf("error getting notion pages: %w", err)
+		return nil, fmt.Errorf("error getting notion pages: %w", err)
+	}
+
+	if listsDB != nil {
+		for i := range notionPages.Pages {
+			notionPages.Pages[i].Lists = listsDB.resolveNames(notionPages.Pages[i].Lists)
+		}
 	}
 
 	log.Info(ctx, fmt.Sprintf("found %d pages in notion", len(notionPages.Pages)))
 
-	log.Info(ctx, "fetching starred repos from github. Depending on the number of starred repos, this might take a while.")
-	starredRepos, err := s.fetchGitHubStarredRepos(ctx)
+	duplicates := duplicatePages(notionPages)
+	for _, duplicate := range duplicates {
+		log.Error(ctx, "repository has duplicate pages in notion, run the dedupe command to merge them",
+			log.String("repo", duplicate.Name),
+			log.String("pages", strings.Join(duplicate.PageIDs, ", ")),
+		)
+	}
+
+	fullSync := starredSince.IsZero()
+	if fullSync {
+		log.Info(ctx, "fetching starred repos from github. Depending on the number of starred repos, this might take a while.")
+	} else {
+		log.Info(ctx, "fetching repos starred since the last sync from github", log.String("since", starredSince.Format(time.RFC3339)))
+	}
+
+	starredRepos, err := s.fetchGitHubStarredRepos(ctx, starredSince)
 	if err != nil {
-		return fmt.Errorf("error getting starred repos: %w", err)
+		return nil, fmt.Errorf("error getting starred repos: %w", err)
 	}
 
 	log.Info(ctx, fmt.Sprintf("found %d starred repos in github", len(starredRepos.Repos)))
 
-	if err := s.doSync(ctx, databaseID, notionPages, starredRepos); err != nil {
-		return fmt.Errorf("error syncing notion database: %w", err)
+	var lists starLists
+	if s.starLists {
+		log.Info(ctx, "fetching star lists from github")
+
+		lists, err = s.fetchGitHubStarLists(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting star lists: %w", err)
+		}
+
+		for i := range starredRepos.Repos {
+			starredRepos.Repos[i].Lists = lists.Of(starredRepos.Repos[i].ID)
+		}
 	}
 
-	return nil
+	// the statistics change all the time, so the ones of the repos that an incremental sync doesn't fetch are
+	// fetched separately, which takes a single request for many repos
+	var stats map[int64]repoStats
+	if !fullSync && fields.hasStats() {
+		log.Info(ctx, "fetching the statistics of the repos starred before the last sync from github")
+
+		stats, err = s.fetchGitHubRepoStats(ctx, unfetchedPages(notionPages, starredRepos))
+		if err != nil {
+			return nil, fmt.Errorf("error getting repository statistics: %w", err)
+		}
+	}
+
+	records, err := s.repoRecords()
+	if err != nil {
+		return nil, fmt.Errorf("error reading sync state: %w", err)
+	}
+
+	plan := s.buildPlan(ctx, databaseID, notionPages, starredRepos, fullSync, records, lists, stats, fields)
+	plan.startedAt = startedAt
+	plan.cursorScope = cursorScope
+	plan.lists = listsDB
+	plan.Duplicates = duplicates
+	plan.existingPages = len(notionPages.Pages)
+	plan.starredRepos = len(starredRepos.Repos)
+	// the pages adopted by the plan were removed from the malformed pages
+	plan.Malformed = notionPages.Malformed
+
+	for _, page := range plan.Malformed {
+		log.Error(ctx, "skipping malformed notion page", log.String("page", page.PageURL), log.String("reason", page.Reason))
+	}
+
+	if plan.Count(ActionMove) > 0 {
+		plan.archive, err = s.loadArchiveDatabase(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting notion archive database: %w", err)
+		}
+	}
+
+	log.Info(ctx, fmt.Sprintf("found %d pages to create", plan.Count(ActionCreate)))
+
+	if count := plan.Count(ActionRestore); count > 0 {
+		log.Info(ctx, fmt.Sprintf("found %d archived pages to restore", count))
+	}
+
+	if count := plan.Count(ActionAdopt); count > 0 {
+		log.Info(ctx, fmt.Sprintf("found %d existing pages to adopt", count))
+	}
+
+	log.Info(ctx, fmt.Sprintf("found %d pages to update", plan.Count(ActionUpdate)))
+	log.Info(ctx, fmt.Sprintf("found %d pages to delete", plan.Count(ActionArchive)))
+
+	if count := plan.Count(ActionMark); count > 0 {
+		log.Info(ctx, fmt.Sprintf("found %d pages to mark as unstarred", count))
+	}
+
+	if count := plan.Count(ActionMove); count > 0 {
+		log.Info(ctx, fmt.Sprintf("found %d pages to move to the archive database", count))
+	}
+
+	return plan, nil
 }
 
+// validateDatabaseFields checks that the notion database has all the required properties, with the right types.
+// The returned error reports every problem at once.
 func (s *Syncer) validateDatabaseFields(database *notionapi.Database) error {
-	for _, requiredProperty := range requiredProperties {
-		if _, ok := database.Properties[requiredProperty.PropertyName]; !ok {
-			return fmt.Errorf("notion database is missing required property %s", requiredProperty.PropertyName)
+	if problems := s.schemaProblems(database); len(problems) > 0 {
+		return schemaError(problems)
+	}
+
+	return nil
+}
+
+// optionalFields returns the optional fields whose property exists in the notion database with the right type.
+// A property with another type is left untouched, since it may be used for something else.
+func (s *Syncer) optionalFields(ctx context.Context, database *notionapi.Database) optionalFields {
+	fields := make(optionalFields, len(optionalProperties))
+
+	for _, optionalProperty := range optionalProperties {
+		propertyName := s.mapping.Name(optionalProperty.Field)
+
+		property, ok := database.Properties[propertyName]
+		if !ok {
+			continue
 		}
 
-		if notionapi.PropertyType(database.Properties[requiredProperty.PropertyName].GetType()) != requiredProperty.PropertyType {
-			return fmt.Errorf("notion database property %s is of type %s, but should be %s", requiredProperty.PropertyName, database.Properties[requiredProperty.PropertyName].GetType(), requiredProperty.PropertyType)
+		actualType := notionapi.PropertyType(property.GetType())
+		if actualType != optionalProperty.PropertyType && !slices.Contains(alternativePropertyTypes[optionalProperty.Field], actualType) {
+			log.Info(ctx, "skipping optional property with an unexpected type",
+				log.String("property", propertyName),
+				log.String("expected", string(optionalProperty.PropertyType)),
+				log.String("actual", string(actualType)),
+			)
+			continue
 		}
+
+		fields[optionalProperty.Field] = actualType
 	}
 
-	return nil
+	return fields
+}
+
+// requiredProperties returns the properties that the notion database must have, depending on the enabled features
+func (s *Syncer) requiredProperties() []RequiredProperty {
+	return databaseProperties(databaseFeatures{
+		starLists:     s.starLists,
+		listsRelation: s.listsDatabaseID != "",
+		starredBy:     s.multipleAccounts(),
+		markUnstarred: s.unstarPolicy == UnstarMark,
+	})
+}
+
+// multipleAccounts tells if the stars of several github accounts are synced to the notion database
+func (s *Syncer) multipleAccounts() bool {
+	return len(s.accounts) > 0
+}
+
+// cursorScope identifies the notion database and the github accounts synced to it, so that a state file used
+// with another database or other accounts doesn't make an incremental sync skip the stars older than its cursor.
+// The accounts authenticated by a token are identified by the login of their user, so that a token of another
+// user doesn't reuse the cursor either. Without a state store, there is no cursor to identify.
+func (s *Syncer) cursorScope(ctx context.Context, databaseID string) (string, error) {
+	if s.state == nil {
+		return "", nil
+	}
+
+	users := make([]string, 0, len(s.accounts)+1)
+	for _, account := range append([]gitHubAccount{{client: s.github, user: s.githubUser}}, s.accounts...) {
+		login, err := account.login(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		users = append(users, login)
+	}
+
+	sort.Strings(users)
+
+	return databaseID + "/" + strings.Join(users, ","), nil
+}
+
+// starredSince returns the time since which the starred repos must be fetched from github.
+// A zero time is returned when a full sync is required, either because there is no state from previous syncs
+// of the same database and accounts or because the last full sync is older than the full sync interval.
+func (s *Syncer) starredSince(scope string, now time.Time) (time.Time, error) {
+	if s.state == nil || s.fullSyncInterval <= 0 {
+		return time.Time{}, nil
+	}
+
+	cursor, err := s.state.Cursor(scope)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if cursor.LastFullSyncAt.IsZero() || now.Sub(cursor.LastFullSyncAt) >= s.fullSyncInterval {
+		return time.Time{}, nil
+	}
+
+	return cursor.LastSyncAt, nil
+}
+
+// repoRecords returns the records of the repos synced by previous syncs, if a state store is used
+func (s *Syncer) repoRecords() (map[int64]state.RepoRecord, error) {
+	if s.state == nil {
+		return map[int64]state.RepoRecord{}, nil
+	}
+
+	return s.state.Repos()
+}
+
+// recordChange saves the outcome of an applied change in the state store
+func (s *Syncer) recordChange(ctx context.Context, change *Change, pageID string) {
+	archived := change.archived
+	if change.repo != nil {
+		archived = change.repo.Lifecycle.Archived
+	}
+
+	s.recordSyncedRepos(ctx, []syncedRepo{
+		{
+			repoID:    change.RepoID,
+			name:      change.Name,
+			pageID:    pageID,
+			unstarred: change.Action.unstars(),
+			archived:  archived,
+			copyID:    change.copyID,
+		},
+	})
+}
+
+// recordSyncedRepos saves the records of the given repos in the state store
+func (s *Syncer) recordSyncedRepos(ctx context.Context, repos []syncedRepo) {
+	if s.state == nil {
+		return
+	}
+
+	for _, repo := range repos {
+		err := s.state.SaveRepo(state.RepoRecord{
+			RepoID:    repo.repoID,
+			PageID:    repo.pageID,
+			Name:      repo.name,
+			Unstarred: repo.unstarred,
+			Archived:  repo.archived,
+			CopyID:    repo.copyID,
+			SyncedAt:  time.Now(),
+		})
+		if err != nil {
+			log.Error(ctx, "error saving sync state", log.String("repo", repo.name), log.String("error", err.Error()))
+		}
+	}
+}
+
+// saveCursor records the start time of a successful sync in the state store
+func (s *Syncer) saveCursor(plan *Plan) error {
+	if s.state == nil {
+		return nil
+	}
+
+	cursor, err := s.state.Cursor(plan.cursorScope)
+	if err != nil {
+		return err
+	}
+
+	cursor.LastSyncAt = plan.startedAt
+	if plan.Full {
+		cursor.LastFullSyncAt = plan.startedAt
+	}
+
+	return s.state.SaveCursor(plan.cursorScope, cursor)
+}
+
+// fetchGitHubStarredRepos returns a collection of starred repos from github.
+// When several accounts are synced, the stars of all the accounts are merged, and each repo records the accounts
+// that starred it.
+func (s *Syncer) fetchGitHubStarredRepos(ctx context.Context, since time.Time) (*starredRepoCollection, error) {
+	primary := gitHubAccount{client: s.github, user: s.githubUser}
+	if !s.multipleAccounts() {
+		return fetchAccountStarredRepos(ctx, primary, since)
+	}
+
+	starredRepos := newStarredRepoCollection()
+
+	for _, account := range append([]gitHubAccount{primary}, s.accounts...) {
+		login, err := account.login(ctx)
+		if err != nil {
+			return starredRepos, fmt.Errorf("error getting github user: %w", err)
+		}
+
+		accountRepos, err := fetchAccountStarredRepos(ctx, account, since)
+		if err != nil {
+			return starredRepos, fmt.Errorf("error getting the stars of %s: %w", login, err)
+		}
+
+		for _, repo := range accountRepos.Repos {
+			existing, ok := starredRepos.Get(repo.ID)
+			if !ok {
+				repo.StarredBy = []string{login}
+				starredRepos.Add(repo)
+				continue
+			}
+
+			if !slices.Contains(existing.StarredBy, login) {
+				existing.StarredBy = append(existing.StarredBy, login)
+			}
+
+			if repo.StarredAt.Before(existing.StarredAt) {
+				existing.StarredAt = repo.StarredAt
+			}
+		}
+	}
+
+	for i := range starredRepos.Repos {
+		slices.Sort(starredRepos.Repos[i].StarredBy)
+	}
+
+	return starredRepos, nil
+}
+
+// gitHubAccount is a github account whose stars are synced
+type gitHubAccount struct {
+	client *github.Client
+	// user is the account whose stars are fetched. When empty, the stars of the authenticated user are fetched.
+	user string
 }
 
-// fetchGitHubStarredRepos returns a collection of starred repos from github
-func (s *Syncer) fetchGitHubStarredRepos(ctx context.Context) (*starredRepoCollection, error) {
+// login returns the name of the account, fetching the authenticated user when no user is set
+func (a gitHubAccount) login(ctx context.Context) (string, error) {
+	if a.user != "" {
+		return a.user, nil
+	}
+
+	user, _, err := a.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	return user.GetLogin(), nil
+}
+
+// fetchAccountStarredRepos returns a collection of the repos starred by the given account.
+// The repos are fetched from the most recently starred to the oldest. When since is not zero, the pagination stops
+// as soon as a repo starred before that time is found.
+func fetchAccountStarredRepos(ctx context.Context, account gitHubAccount, since time.Time) (*starredRepoCollection, error) {
 	starredRepos := newStarredRepoCollection()
 
 	opt := &github.ActivityListStarredOptions{
+		Sort:        "created",
+		Direction:   "desc",
 		ListOptions: github.ListOptions{PerPage: githubReposPerPage},
 	}
 
 	for {
-		repos, resp, err := s.github.Activity.ListStarred(ctx, "", opt)
+		repos, resp, err := account.client.Activity.ListStarred(ctx, account.user, opt)
 		if err != nil {
 			return starredRepos, err
 		}
 
 		for _, repo := range repos {
+			if !since.IsZero() && repo.StarredAt.Time.Before(since) {
+				return starredRepos, nil
+			}
+
 			starredRepos.Add(starredRepo{
-				ID:          repo.Repository.GetID(),
-				Name:        repo.Repository.GetName(),
-				Description: repo.Repository.GetDescription(),
-				URL:         repo.Repository.GetHTMLURL(),
-				Topics:      repo.Repository.Topics,
-				Language:    repo.Repository.GetLanguage(),
-				StarredAt:   repo.StarredAt.Time,
+				ID:             repo.Repository.GetID(),
+				Name:           repo.Repository.GetName(),
+				Owner:          repo.Repository.GetOwner().GetLogin(),
+				OwnerType:      repo.Repository.GetOwner().GetType(),
+				OwnerAvatarURL: repo.Repository.GetOwner().GetAvatarURL(),
+				Description:    repo.Repository.GetDescription(),
+				URL:            repo.Repository.GetHTMLURL(),
+				Topics:         repo.Repository.Topics,
+				Language:       repo.Repository.GetLanguage(),
+				StarredAt:      repo.StarredAt.Time,
+				Stats: repoStats{
+					Stars:      repo.Repository.GetStargazersCount(),
+					Forks:      repo.Repository.GetForksCount(),
+					OpenIssues: repo.Repository.GetOpenIssuesCount(),
+				},
+				Lifecycle: repoLifecycle{
+					Archived:  repo.Repository.GetArchived(),
+					Fork:      repo.Repository.GetFork(),
+					Template:  repo.Repository.GetIsTemplate(),
+					PushedAt:  repo.Repository.GetPushedAt().Time,
+					CreatedAt: repo.Repository.GetCreatedAt().Time,
+					UpdatedAt: repo.Repository.GetUpdatedAt().Time,
+				},
 			})
 		}
 
@@ -129,36 +624,79 @@ func (s *Syncer) fetchGitHubStarredRepos(ctx context.Context) (*starredRepoColle
 			break
 		}
 
+		if err := waitForGitHubRateLimit(ctx, resp.Rate); err != nil {
+			return starredRepos, err
+		}
+
 		opt.Page = resp.NextPage
 	}
 
 	return starredRepos, nil
 }
 
+// waitForGitHubRateLimit waits until the github rate limit resets, when there are no requests left.
+// Unauthenticated requests are limited to 60 per hour, which is not enough to fetch a large number of stars.
+// Waiting for the reset allows the sync to finish, instead of failing half way through the pagination.
+func waitForGitHubRateLimit(ctx context.Context, rate github.Rate) error {
+	if rate.Limit == 0 || rate.Remaining > 0 {
+		return nil
+	}
+
+	wait := time.Until(rate.Reset.Time)
+	if wait <= 0 {
+		return nil
+	}
+
+	log.Info(ctx, "github rate limit exceeded, waiting for it to reset", log.String("reset", rate.Reset.Time.Format(time.RFC3339)))
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
 // getPagesFromNotionDatabase returns a collection of pages from the specified notion database
 func (s *Syncer) getPagesFromNotionDatabase(ctx context.Context, databaseID notionapi.DatabaseID) (*databasePages, error) {
 	pages := newDatabasePages()
+
+	results, err := s.queryNotionDatabase(ctx, databaseID)
+	for _, result := range results {
+		page, parseErr := parseNotionPage(result, s.mapping)
+		if parseErr != nil {
+			pages.AddMalformed(result, page, parseErr)
+			continue
+		}
+
+		pages.Add(page)
+	}
+
+	return pages, err
+}
+
+// queryNotionDatabase returns all the pages of the notion database, going through every page of results
+func (s *Syncer) queryNotionDatabase(ctx context.Context, databaseID notionapi.DatabaseID) ([]notionapi.Page, error) {
+	results := make([]notionapi.Page, 0)
 	cursor := notionapi.Cursor("")
 
 	for {
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return results, err
+		}
+
 		resp, err := s.notion.Database.Query(ctx, databaseID, &notionapi.DatabaseQueryRequest{
 			PageSize:    notionPagesPerPage,
 			StartCursor: cursor,
 		})
 		if err != nil {
-			return pages, err
+			return results, err
 		}
 
-		for _, result := range resp.Results {
-			titleProperty := result.Properties[databasePropertyTitle].(*notionapi.TitleProperty)
-			repoIDProperty := result.Properties[databasePropertyRepoID].(*notionapi.NumberProperty)
-
-			pages.Add(notionPage{
-				ID:       result.ID.String(),
-				Title:    titleProperty.Title[0].PlainText,
-				GitHubID: int64(repoIDProperty.Number),
-			})
-		}
+		results = append(results, resp.Results...)
 
 		if !resp.HasMore {
 			break
@@ -167,63 +705,184 @@ func (s *Syncer) getPagesFromNotionDatabase(ctx context.Context, databaseID noti
 		cursor = resp.NextCursor
 	}
 
-	return pages, nil
+	return results, nil
 }
 
-// doSync compares the notion pages with the starred repos and creates/deletes the notion pages accordingly
-func (s *Syncer) doSync(ctx context.Context, databaseID notionapi.DatabaseID, notionPages *databasePages, starredRepos *starredRepoCollection) error {
-	pagesToCreate := make([]starredRepo, 0)
-	pagesToDelete := make([]notionPage, 0)
+// applyPlan applies the changes of the plan to the notion database.
+// The changes are applied concurrently by a pool of workers, which share the notion rate limiter.
+// A failed change does not stop the sync. Instead, its error is recorded in the returned result.
+func (s *Syncer) applyPlan(ctx context.Context, plan *Plan) *SyncResult {
+	result := &SyncResult{
+		Skipped: plan.Skipped,
+	}
 
-	// find the pages that need to be created (i.e. starred repos that are not in the notion database)
-	for _, repo := range starredRepos.Repos {
-		if !notionPages.ContainsRepo(repo.ID) {
-			pagesToCreate = append(pagesToCreate, repo)
-		}
+	changes := make(chan *Change)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for change := range changes {
+				pageID, err := s.applyChange(ctx, plan, change)
+				if err != nil {
+					log.Error(ctx, "error syncing notion page", log.String("action", string(change.Action)), log.String("repo", change.Name), log.String("error", err.Error()))
+					result.recordFailure(change, err)
+					continue
+				}
+
+				log.Info(ctx, "notion page synced", log.String("action", string(change.Action)), log.String("repo", change.Name))
+				result.recordSuccess(change.Action)
+				s.recordChange(ctx, change, pageID)
+			}
+		}()
 	}
 
-	// find the pages that need to be deleted (i.e. notion pages whose repo is not starred anymore)
-	for _, page := range notionPages.Pages {
-		if !starredRepos.Contains(page.GitHubID) {
-			pagesToDelete = append(pagesToDelete, page)
-		}
+	for i := range plan.Changes {
+		changes <- &plan.Changes[i]
 	}
 
-	log.Info(ctx, fmt.Sprintf("found %d pages to create", len(pagesToCreate)))
+	close(changes)
+	wg.Wait()
 
-	for _, repo := range pagesToCreate {
-		if err := s.createNotionPage(ctx, databaseID, &repo); err != nil {
-			log.Error(ctx, "error creating notion page", log.String("repo", repo.Name), log.String("error", err.Error()))
-			continue
+	return result
+}
+
+// applyChange applies a single change of the plan to the notion database and returns the ID of the changed page
+func (s *Syncer) applyChange(ctx context.Context, plan *Plan, change *Change) (string, error) {
+	switch change.Action {
+	case ActionCreate:
+		return s.createNotionPage(ctx, plan, change.repo)
+	case ActionUpdate, ActionAdopt:
+		return change.PageID, s.updateNotionPage(ctx, plan, notionapi.PageID(change.PageID), change.request)
+	case ActionArchive:
+		return change.PageID, s.deleteNotionPage(ctx, notionapi.PageID(change.PageID))
+	case ActionMark:
+		return change.PageID, s.updateNotionPage(ctx, plan, notionapi.PageID(change.PageID), change.request)
+	case ActionMove:
+		// the original page is recorded instead of its copy, since it is the one restored if the repo is starred again
+		copyID, err := s.moveNotionPage(ctx, plan.archive, notionapi.PageID(change.PageID))
+		change.copyID = copyID
+
+		return change.PageID, err
+	case ActionRestore:
+		return s.restoreNotionPage(ctx, plan, change)
+	default:
+		return "", fmt.Errorf("unknown change action %s", change.Action)
+	}
+}
+
+func (s *Syncer) createNotionPage(ctx context.Context, plan *Plan, repo *starredRepo) (string, error) {
+	request := buildCreatePageRequestFromRepo(notionapi.DatabaseID(plan.DatabaseID), repo, s.mapping, plan.fields)
+
+	properties, err := s.resolveListRelations(ctx, plan.lists, request.Properties)
+	if err != nil {
+		return "", err
+	}
+
+	request.Properties = properties
+
+	var blocks []notionapi.Block
+	if s.readme {
+		// the readme is only the body of the page, so the page is created without it when it can't be fetched
+		blocks, err = s.fetchReadmeBlocks(ctx, repo)
+		if err != nil {
+			log.Error(ctx, "error fetching the readme, the page is created without it", log.String("repo", repo.Name), log.String("error", err.Error()))
 		}
 
-		log.Info(ctx, "notion page created", log.String("repo", repo.Name))
+		// the page is created with the first blocks, and the remaining ones are appended afterwards
+		request.Children = blocks[:min(len(blocks), markdown.MaxBlocksPerRequest)]
+		blocks = blocks[len(request.Children):]
 	}
 
-	log.Info(ctx, fmt.Sprintf("found %d pages to delete", len(pagesToDelete)))
-	for _, page := range pagesToDelete {
-		if err := s.deleteNotionPage(ctx, notionapi.PageID(page.ID)); err != nil {
-			log.Error(ctx, "error deleting notion page", log.String("page", page.Title), log.String("error", err.Error()))
-			continue
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	page, err := s.notion.Page.Create(ctx, request)
+
+	// notion rejects the whole request when one of the blocks is invalid, so the page is created again without them
+	if isNotionValidationError(err) && len(request.Children) > 0 {
+		log.Error(ctx, "error adding the readme to the page, the page is created without it", log.String("repo", repo.Name), log.String("error", err.Error()))
+
+		request.Children = nil
+		blocks = nil
+
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return "", err
 		}
 
-		log.Info(ctx, "notion page deleted", log.String("page", page.Title))
+		page, err = s.notion.Page.Create(ctx, request)
 	}
 
-	return nil
+	if err != nil {
+		return "", err
+	}
+
+	// the page was created, so a failure to append the rest of the readme only leaves its body incomplete
+	if err := s.appendPageBlocks(ctx, page.ID, blocks); err != nil {
+		log.Error(ctx, "error adding the readme to the page", log.String("repo", repo.Name), log.String("error", err.Error()))
+	}
+
+	return page.ID.String(), nil
 }
 
-func (s *Syncer) createNotionPage(ctx context.Context, databaseID notionapi.DatabaseID, repo *starredRepo) error {
-	request := buildCreatePageRequestFromRepo(databaseID, repo)
-	_, err := s.notion.Page.Create(ctx, request)
+func (s *Syncer) updateNotionPage(ctx context.Context, plan *Plan, pageID notionapi.PageID, request *notionapi.PageUpdateRequest) error {
+	properties, err := s.resolveListRelations(ctx, plan.lists, request.Properties)
+	if err != nil {
+		return err
+	}
+
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	_, err = s.notion.Page.Update(ctx, pageID, &notionapi.PageUpdateRequest{
+		Properties: properties,
+		Archived:   request.Archived,
+		Icon:       request.Icon,
+	})
 
 	return err
 }
 
 func (s *Syncer) deleteNotionPage(ctx context.Context, pageID notionapi.PageID) error {
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return err
+	}
+
 	_, err := s.notion.Page.Update(ctx, pageID, &notionapi.PageUpdateRequest{
 		Archived: true,
 	})
 
 	return err
 }
+
+// NewNotionLimiter creates a token bucket limiter that allows the given average of requests per second,
+// with bursts of up to one second worth of requests. A value of zero disables the rate limit.
+func NewNotionLimiter(rps float64) *rate.Limiter {
+	if rps == 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+
+	return rate.NewLimiter(rate.Limit(rps), int(math.Ceil(rps)))
+}
+
+// IsNotionReadOnly tells if a request to the notion api only reads data. The database queries and the searches are
+// sent with POST, but they can be sent again when they fail, like a GET request.
+func IsNotionReadOnly(req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		return false
+	}
+
+	path := strings.TrimSuffix(req.URL.Path, "/")
+
+	return path == "/v1/search" || (strings.HasPrefix(path, "/v1/databases/") && strings.HasSuffix(path, "/query"))
+}
+
+// IsGitHubStarsRequest tells if a request to the github api lists starred repos. Their responses are requested
+// again on every sync, so they are worth caching, unlike the other requests, like the ones of the READMEs.
+func IsGitHubStarsRequest(req *http.Request) bool {
+	return strings.HasSuffix(strings.TrimSuffix(req.URL.Path, "/"), "/starred")
+}
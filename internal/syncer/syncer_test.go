@@ -1,14 +1,24 @@
 package syncer_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/brpaz/github-stars-notion-sync/internal/retry"
+	"github.com/brpaz/github-stars-notion-sync/internal/state"
 	"github.com/brpaz/github-stars-notion-sync/internal/syncer"
 	"github.com/google/go-github/v57/github"
 	"github.com/h2non/gock"
@@ -40,6 +50,34 @@ func loadFixture(t *testing.T, path string) []byte {
 	return jsonData
 }
 
+// bodyMatcher decodes the body of the request and matches it with the given function
+func bodyMatcher(match func(body map[string]any) bool) gock.MatchFunc {
+	return func(req *http.Request, _ *gock.Request) (bool, error) {
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return false, err
+		}
+
+		req.Body = io.NopCloser(bytes.NewReader(data))
+
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			return false, err
+		}
+
+		return match(body), nil
+	}
+}
+
+// mockGitHubUser mocks the user authenticated by the github token, which identifies the cursor of the incremental
+// syncs
+func mockGitHubUser() {
+	gock.New(githubAPIURL).
+		Get("/user$").
+		Reply(200).
+		JSON(map[string]any{"login": "brpaz"})
+}
+
 func TestSyncer_New(t *testing.T) {
 	t.Parallel()
 
@@ -60,6 +98,38 @@ func TestSyncer_New(t *testing.T) {
 		assert.Nil(t, syncerSvc)
 	})
 
+	t.Run("should return error if property mapping has unknown fields", func(t *testing.T) {
+		t.Parallel()
+		syncerSvc, err := syncer.New(github.NewClient(nil), &notionapi.Client{}, syncer.WithPropertyMapping(syncer.PropertyMapping{
+			"unknown": "Unknown",
+		}))
+		assert.ErrorContains(t, err, `unknown property mapping field "unknown"`)
+		assert.Nil(t, syncerSvc)
+	})
+
+	t.Run("should return error if property mapping uses the same property for different fields", func(t *testing.T) {
+		t.Parallel()
+		syncerSvc, err := syncer.New(github.NewClient(nil), &notionapi.Client{}, syncer.WithPropertyMapping(syncer.PropertyMapping{
+			syncer.FieldDescription: "Topics",
+		}))
+		assert.ErrorContains(t, err, `notion property "Topics" is mapped to both description and topics fields`)
+		assert.Nil(t, syncerSvc)
+	})
+
+	t.Run("should return error if concurrency is not positive", func(t *testing.T) {
+		t.Parallel()
+		syncerSvc, err := syncer.New(github.NewClient(nil), &notionapi.Client{}, syncer.WithConcurrency(0))
+		assert.ErrorIs(t, err, syncer.ErrInvalidConcurrency)
+		assert.Nil(t, syncerSvc)
+	})
+
+	t.Run("should return error if notion rate limit is negative", func(t *testing.T) {
+		t.Parallel()
+		syncerSvc, err := syncer.New(github.NewClient(nil), &notionapi.Client{}, syncer.WithNotionRateLimit(-1))
+		assert.ErrorIs(t, err, syncer.ErrInvalidNotionRPS)
+		assert.Nil(t, syncerSvc)
+	})
+
 	t.Run("should return syncer instance if all arguments are valid", func(t *testing.T) {
 		t.Parallel()
 		syncerSvc, err := syncer.New(github.NewClient(nil), &notionapi.Client{})
@@ -69,7 +139,7 @@ func TestSyncer_New(t *testing.T) {
 }
 
 func TestSyncer_SyncStars(t *testing.T) {
-	syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""))
+	syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
 	require.NoError(t, err)
 
 	t.Run("should return error if notion database does not exist", func(t *testing.T) {
@@ -82,7 +152,7 @@ func TestSyncer_SyncStars(t *testing.T) {
 			Reply(404).
 			JSON(notionAPIResponse)
 
-		err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+		_, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "Could not find database with ID")
@@ -100,13 +170,35 @@ func TestSyncer_SyncStars(t *testing.T) {
 			Reply(401).
 			JSON(notionAPIResponse)
 
-		err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+		_, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
 
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "API token is invalid")
 		assert.True(t, gock.IsDone())
 	})
 
+	t.Run("should return error if notion database does not match the property mapping", func(t *testing.T) {
+		notionGetDatabaseResponse := loadFixture(t, path.Join("notionapi", "get_database_response.json"))
+		mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(notionGetDatabaseResponse)
+
+		mappedSyncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithPropertyMapping(syncer.PropertyMapping{
+			syncer.FieldTitle: "Repo",
+		}))
+		require.NoError(t, err)
+
+		_, err = mappedSyncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		assert.ErrorContains(t, err, "notion database is missing required property Repo")
+		assert.True(t, gock.IsDone())
+	})
+
 	/**
 	* This test should sync 3 pages:
 	* - 1 page that exists in the database but not in github (will be deleted)
@@ -165,9 +257,3016 @@ func TestSyncer_SyncStars(t *testing.T) {
 			Reply(200).
 			JSON(createPageResponse)
 
-		err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		assert.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 1, result.Archived)
+		assert.Equal(t, 0, result.Failed)
+	})
+
+	t.Run("reports the changes that failed", func(t *testing.T) {
+		notionGetDatabaseResponse := loadFixture(t, path.Join("notionapi", "get_database_response.json"))
+		notionDatabasePagesResponse := loadFixture(t, path.Join("notionapi", "get_database_pages_response.json"))
+		githubStarredReposResponse := loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json"))
+
+		createPage1Request := loadFixture(t, path.Join("notionapi", "create_page_1_request.json"))
+		createPage2Request := loadFixture(t, path.Join("notionapi", "create_page_2_request.json"))
+		createPage3Request := loadFixture(t, path.Join("notionapi", "create_page_3_request.json"))
+
+		createPageResponse := loadFixture(t, path.Join("notionapi", "create_page_response.json"))
+		validationErrorResponse := loadFixture(t, path.Join("notionapi", "validation_error_response.json"))
+		mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(notionGetDatabaseResponse)
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(notionDatabasePagesResponse)
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(githubStarredReposResponse)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(createPage1Request)).
+			Reply(200).
+			JSON(createPageResponse)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(createPage2Request)).
+			Reply(400).
+			JSON(validationErrorResponse)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(createPage3Request)).
+			Reply(200).
+			JSON(createPageResponse)
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(createPageResponse)
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		assert.ErrorContains(t, err, "1 of 4 changes failed")
+		assert.ErrorContains(t, err, "error applying create to adguard-home-manager")
+		assert.True(t, gock.IsDone())
+		require.NotNil(t, result)
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Archived)
+		assert.Equal(t, 1, result.Failed)
+		assert.Error(t, result.Errors)
+	})
+
+	/**
+	* This test should sync 3 pages:
+	* - 1 page that exists in the database but has outdated metadata (will be updated)
+	* - 2 pages that exists in github but not in the database (will be created)
+	 */
+	t.Run("updates pages with outdated metadata", func(t *testing.T) {
+		notionGetDatabaseResponse := loadFixture(t, path.Join("notionapi", "get_database_response.json"))
+		notionDatabasePagesResponse := loadFixture(t, path.Join("notionapi", "get_database_pages_outdated_response.json"))
+		githubStarredReposResponse := loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json"))
+
+		createPage1Request := loadFixture(t, path.Join("notionapi", "create_page_1_request.json"))
+		createPage2Request := loadFixture(t, path.Join("notionapi", "create_page_2_request.json"))
+		updatePageRequest := loadFixture(t, path.Join("notionapi", "update_page_request.json"))
+
+		createPageResponse := loadFixture(t, path.Join("notionapi", "create_page_response.json"))
+		mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(notionGetDatabaseResponse)
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(notionDatabasePagesResponse)
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(githubStarredReposResponse)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(createPage1Request)).
+			Reply(200).
+			JSON(createPageResponse)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(createPage2Request)).
+			Reply(200).
+			JSON(createPageResponse)
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b").
+			BodyString(string(updatePageRequest)).
+			Reply(200).
+			JSON(createPageResponse)
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
 
 		assert.NoError(t, err)
 		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Updated)
+	})
+}
+
+func TestSyncer_Plan(t *testing.T) {
+	syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+	require.NoError(t, err)
+
+	t.Run("returns the planned changes without modifying the notion database", func(t *testing.T) {
+		notionGetDatabaseResponse := loadFixture(t, path.Join("notionapi", "get_database_response.json"))
+		notionDatabasePagesResponse := loadFixture(t, path.Join("notionapi", "get_database_pages_response.json"))
+		githubStarredReposResponse := loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json"))
+		mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(notionGetDatabaseResponse)
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(notionDatabasePagesResponse)
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(githubStarredReposResponse)
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, mockDatabaseID, plan.DatabaseID)
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 0, plan.Count(syncer.ActionUpdate))
+		assert.Equal(t, 1, plan.Count(syncer.ActionArchive))
+
+		archived := plan.Changes[3]
+		assert.Equal(t, syncer.ActionArchive, archived.Action)
+		assert.Equal(t, "nostr-rs-relay", archived.Name)
+		assert.Equal(t, "9ef240ab-18de-4808-92ee-22f6dce028e9", archived.PageID)
+	})
+}
+
+func TestSyncer_SyncStars_Incremental(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	// the cursor of the database synced with the stars of the authenticated user
+	mockCursorScope := mockDatabaseID + "/brpaz"
+
+	mockNotionAndGitHub := func(t *testing.T, databaseResponse string) {
+		t.Helper()
+		mockGitHubUser()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", databaseResponse)))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			MatchParam("sort", "created").
+			MatchParam("direction", "desc").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+	}
+
+	t.Run("runs a full sync and saves the cursor when there is no previous sync", func(t *testing.T) {
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStateStore(store))
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json")
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, plan.Full)
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 1, plan.Count(syncer.ActionArchive))
+
+		cursor, err := store.Cursor(mockCursorScope)
+		require.NoError(t, err)
+		assert.True(t, cursor.LastSyncAt.IsZero(), "planning must not move the cursor")
+	})
+
+	t.Run("only fetches the repos starred since the last sync", func(t *testing.T) {
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		lastSyncAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		require.NoError(t, store.SaveCursor(mockCursorScope, state.Cursor{
+			LastSyncAt:     lastSyncAt,
+			LastFullSyncAt: time.Now().Add(-time.Hour),
+		}))
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStateStore(store))
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json")
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_1_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_2_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 0, result.Archived)
+
+		cursor, err := store.Cursor(mockCursorScope)
+		require.NoError(t, err)
+		assert.True(t, cursor.LastSyncAt.After(lastSyncAt))
+	})
+
+	t.Run("runs a full sync when the last full sync is older than the interval", func(t *testing.T) {
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		require.NoError(t, store.SaveCursor(mockCursorScope, state.Cursor{
+			LastSyncAt:     time.Now().Add(-time.Hour),
+			LastFullSyncAt: time.Now().Add(-48 * time.Hour),
+		}))
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+			syncer.WithNotionRateLimit(0),
+			syncer.WithStateStore(store),
+			syncer.WithFullSyncInterval(24*time.Hour),
+		)
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json")
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, plan.Full)
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 1, plan.Count(syncer.ActionArchive))
+	})
+
+	t.Run("runs a full sync when the cursor is from another database or other accounts", func(t *testing.T) {
+		// the cursor of another database, and the cursor of another user, whose token was replaced
+		for _, scope := range []string{"52a27820-f777-42d7-9331-0eeb9805770f/brpaz", mockDatabaseID + "/octocat"} {
+			store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+			require.NoError(t, err)
+			defer store.Close()
+
+			require.NoError(t, store.SaveCursor(scope, state.Cursor{
+				LastSyncAt:     time.Now().Add(-time.Hour),
+				LastFullSyncAt: time.Now().Add(-time.Hour),
+			}))
+
+			syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStateStore(store))
+			require.NoError(t, err)
+
+			defer gock.Off()
+			mockNotionAndGitHub(t, "get_database_response.json")
+
+			plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+			require.NoError(t, err)
+			assert.True(t, plan.Full, scope)
+			assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		}
+	})
+
+	// newIncrementalSyncer returns a syncer whose last sync, which was not a full sync, happened at the start of 2024
+	newIncrementalSyncer := func(t *testing.T) *syncer.Syncer {
+		t.Helper()
+
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+
+		require.NoError(t, store.SaveCursor(mockCursorScope, state.Cursor{
+			LastSyncAt:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			LastFullSyncAt: time.Now().Add(-time.Hour),
+		}))
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStateStore(store))
+		require.NoError(t, err)
+
+		return syncerSvc
+	}
+
+	// statsMatcher matches the statistics queries of the repo of the existing page
+	statsMatcher := bodyMatcher(func(body map[string]any) bool {
+		variables, _ := body["variables"].(map[string]any)
+		query, _ := body["query"].(string)
+
+		return variables["owner0"] == "scsibug" && variables["name0"] == "nostr-rs-relay" && strings.Contains(query, "stargazerCount")
+	})
+
+	t.Run("refreshes the statistics of the repos starred before the last sync", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_optional_properties_response.json")
+
+		gock.New(githubAPIURL).
+			Post("/graphql").
+			AddMatcher(statsMatcher).
+			Reply(200).
+			JSON(map[string]any{
+				"data": map[string]any{
+					"r0": map[string]any{
+						"databaseId":     431715396,
+						"stargazerCount": 2100,
+						"forkCount":      150,
+						"issues":         map[string]any{"totalCount": 80},
+						"pullRequests":   map[string]any{"totalCount": 12},
+					},
+				},
+			})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				properties, _ := body["properties"].(map[string]any)
+				stars, _ := properties["Stars"].(map[string]any)
+				forks, _ := properties["Forks"].(map[string]any)
+				issues, _ := properties["Open issues"].(map[string]any)
+
+				return len(properties) == 3 && stars["number"] == float64(2100) && forks["number"] == float64(150) && issues["number"] == float64(92)
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := newIncrementalSyncer(t).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Updated)
+		assert.Equal(t, 0, result.Archived)
+	})
+
+	t.Run("leaves the pages of the deleted repos untouched", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_optional_properties_response.json")
+
+		gock.New(githubAPIURL).
+			Post("/graphql").
+			AddMatcher(statsMatcher).
+			Reply(200).
+			JSON(map[string]any{
+				"data": map[string]any{"r0": nil},
+				"errors": []any{
+					map[string]any{"type": "NOT_FOUND", "path": []any{"r0"}, "message": "Could not resolve to a Repository with the name 'scsibug/nostr-rs-relay'."},
+				},
+			})
+
+		plan, err := newIncrementalSyncer(t).Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.False(t, plan.Full)
+		assert.Equal(t, 2, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 0, plan.Count(syncer.ActionUpdate))
+	})
+}
+
+func TestSyncer_SyncStars_WithStateStore(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+		syncer.WithNotionRateLimit(0),
+		syncer.WithStateStore(store),
+		syncer.WithFullSyncInterval(0),
+	)
+	require.NoError(t, err)
+
+	mockNotionAndGitHub := func(t *testing.T) {
+		t.Helper()
+		mockGitHubUser()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_outdated_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+	}
+
+	t.Run("records the synced repos", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "update_page_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Updated)
+
+		records, err := store.Repos()
+		require.NoError(t, err)
+		assert.Len(t, records, 3)
+		assert.Equal(t, "f0a80e0c-0d3f-4c1f-8c7f-396eb8aebf7b", records[423249811].PageID)
+		assert.Equal(t, "b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b", records[40733543].PageID)
+		assert.Equal(t, "webextensions-examples", records[40733543].Name)
+	})
+
+	t.Run("repairs the pages edited in notion even if the repo didn't change since the last sync", func(t *testing.T) {
+		defer gock.Off()
+		// the page still has the outdated values, as if they were edited by hand after the last sync
+		mockNotionAndGitHub(t)
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, plan.Count(syncer.ActionUpdate))
+		assert.Equal(t, 0, plan.Skipped)
+	})
+}
+
+func TestSyncer_SyncStars_Concurrency(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	t.Run("applies the changes with several workers sharing the rate limiter", func(t *testing.T) {
+		defer gock.Off()
+
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		var stars []map[string]any
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")), &stars))
+
+		// the stars of the fixture are repeated with different ids, so that each worker creates several pages
+		repoIDs := make([]int64, 0, 12)
+		manyStars := make([]map[string]any, 0, 12)
+		for i := 0; i < 12; i++ {
+			var star map[string]any
+			data, err := json.Marshal(stars[i%len(stars)])
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(data, &star))
+
+			repoID := int64(1000 + i)
+			repo := star["repo"].(map[string]any)
+			repo["id"] = repoID
+			repo["name"] = fmt.Sprintf("repo-%d", i)
+
+			repoIDs = append(repoIDs, repoID)
+			manyStars = append(manyStars, star)
+		}
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(map[string]any{"object": "list", "results": []any{}, "has_more": false})
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(manyStars)
+
+		// each created page gets its own id, to check that every repo is recorded with the page created for it
+		for _, repoID := range repoIDs {
+			repoID := repoID
+			gock.New(notionAPIURL).
+				Post("/v1/pages").
+				AddMatcher(bodyMatcher(func(body map[string]any) bool {
+					properties, _ := body["properties"].(map[string]any)
+					property, _ := properties["Repository ID"].(map[string]any)
+					return property["number"] == float64(repoID)
+				})).
+				Reply(200).
+				JSON(map[string]any{"object": "page", "id": fmt.Sprintf("00000000-0000-0000-0000-%012d", repoID)})
+		}
+
+		mockGitHubUser()
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+			syncer.WithNotionRateLimit(1000),
+			syncer.WithConcurrency(4),
+			syncer.WithStateStore(store),
+		)
+		require.NoError(t, err)
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, len(repoIDs), result.Created)
+		assert.Equal(t, 0, result.Failed)
+
+		records, err := store.Repos()
+		require.NoError(t, err)
+		require.Len(t, records, len(repoIDs))
+		for _, repoID := range repoIDs {
+			assert.Equal(t, fmt.Sprintf("00000000-0000-0000-0000-%012d", repoID), records[repoID].PageID)
+		}
+	})
+}
+
+func TestSyncer_SyncStars_TransientErrors(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	t.Run("retries the database query that failed with a transient error", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(503).
+			JSON(map[string]any{"object": "error", "status": 503, "code": "service_unavailable", "message": "Notion is unavailable"})
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		transport := retry.NewTransport(nil, retry.Config{MaxRetries: 1, MinWait: time.Millisecond, MaxWait: time.Second})
+		transport.ReadOnly = syncer.IsNotionReadOnly
+		notionClient := notionapi.NewClient("", notionapi.WithHTTPClient(&http.Client{Transport: transport}))
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionClient, syncer.WithNotionRateLimit(0))
+		require.NoError(t, err)
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 1, result.Archived)
+	})
+}
+
+func TestIsNotionReadOnly(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected bool
+	}{
+		{method: http.MethodPost, path: "/v1/databases/705baa92-0ea9-4a4f-bb97-4916d1cb45bc/query", expected: true},
+		{method: http.MethodPost, path: "/v1/search", expected: true},
+		{method: http.MethodPost, path: "/v1/pages", expected: false},
+		{method: http.MethodPatch, path: "/v1/databases/705baa92-0ea9-4a4f-bb97-4916d1cb45bc", expected: false},
+		{method: http.MethodPatch, path: "/v1/blocks/705baa92-0ea9-4a4f-bb97-4916d1cb45bc/children", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, notionAPIURL+tc.path, nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, syncer.IsNotionReadOnly(req))
+		})
+	}
+}
+
+func TestIsGitHubStarsRequest(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{path: "/user/starred", expected: true},
+		{path: "/users/octocat/starred", expected: true},
+		{path: "/repos/brpaz/github-stars-notion-sync/readme", expected: false},
+		{path: "/user", expected: false},
+		{path: "/rate_limit", expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, githubAPIURL+tc.path+"?per_page=100&page=2", nil)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, syncer.IsGitHubStarsRequest(req))
+		})
+	}
+}
+
+func TestSyncer_SyncStars_StarredAgain(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	mockArchivedPageID := "b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b"
+
+	mockCopyID := "c7d8e9f0-1a2b-4c3d-8e4f-5a6b7c8d9e0f"
+
+	// newSyncer returns a syncer whose state holds the record of the unstarred repo, with the copy of its page
+	// in the archive database when it was moved there
+	newSyncer := func(t *testing.T, copyID string) (*syncer.Syncer, *state.Store) {
+		t.Helper()
+
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+
+		// the repo was unstarred, and its page archived, by a previous sync
+		require.NoError(t, store.SaveRepo(state.RepoRecord{
+			RepoID:    40733543,
+			PageID:    mockArchivedPageID,
+			Name:      "webextensions-examples",
+			Unstarred: true,
+			CopyID:    copyID,
+			SyncedAt:  time.Now(),
+		}))
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+			syncer.WithNotionRateLimit(0),
+			syncer.WithStateStore(store),
+			syncer.WithFullSyncInterval(0),
+		)
+		require.NoError(t, err)
+
+		return syncerSvc, store
+	}
+
+	mockNotionAndGitHub := func(t *testing.T) {
+		t.Helper()
+		mockGitHubUser()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+	}
+
+	t.Run("plans to restore the archived page", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		syncerSvc, _ := newSyncer(t, "")
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.Equal(t, 2, plan.Count(syncer.ActionCreate))
+		require.Equal(t, 1, plan.Count(syncer.ActionRestore))
+
+		restored := plan.Changes[2]
+		assert.Equal(t, "webextensions-examples", restored.Name)
+		assert.Equal(t, mockArchivedPageID, restored.PageID)
+	})
+
+	t.Run("unarchives and refreshes the archived page", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/pages/%s", mockArchivedPageID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_archived_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockArchivedPageID)).
+			BodyString(`{"properties":{},"archived":false}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockArchivedPageID)).
+			BodyString(string(loadFixture(t, path.Join("notionapi", "update_page_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		syncerSvc, store := newSyncer(t, "")
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Restored)
+		assert.Equal(t, 1, result.Archived)
+
+		records, err := store.Repos()
+		require.NoError(t, err)
+		assert.Equal(t, mockArchivedPageID, records[40733543].PageID)
+		assert.False(t, records[40733543].Unstarred)
+	})
+
+	t.Run("creates a new page when the archived page no longer exists", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/pages/%s", mockArchivedPageID)).
+			Reply(404).
+			JSON(map[string]any{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find page"})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		syncerSvc, store := newSyncer(t, "")
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 0, result.Restored)
+
+		records, err := store.Repos()
+		require.NoError(t, err)
+		assert.Equal(t, "f0a80e0c-0d3f-4c1f-8c7f-396eb8aebf7b", records[40733543].PageID)
+	})
+
+	t.Run("archives the copy of the page moved to the archive database", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/pages/%s", mockArchivedPageID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_archived_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockArchivedPageID)).
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockCopyID)).
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		syncerSvc, store := newSyncer(t, mockCopyID)
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, result.Restored)
+
+		records, err := store.Repos()
+		require.NoError(t, err)
+		assert.Equal(t, mockArchivedPageID, records[40733543].PageID)
+		assert.Empty(t, records[40733543].CopyID)
+	})
+}
+
+func TestSyncer_SyncStars_StarLists(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	mockListsDatabaseID := "3f7c9a1e-2b4d-4c6e-8f0a-1b2c3d4e5f60"
+
+	mockNotionAndGitHub := func(t *testing.T, databaseResponse string) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", databaseResponse)))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_outdated_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+	}
+
+	t.Run("should return error if notion database has no lists property", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStarLists())
+		require.NoError(t, err)
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		_, err = syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		assert.ErrorContains(t, err, "notion database is missing required property Lists")
+	})
+
+	t.Run("should return error if star lists can't be fetched", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStarLists())
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_lists_response.json")
+
+		gock.New(githubAPIURL).
+			Post("/graphql").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "graphql_error_response.json")))
+
+		_, err = syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		assert.ErrorContains(t, err, "error getting star lists: github graphql error: Your token has not been granted the required scopes")
+	})
+
+	t.Run("syncs the star lists to a multi-select property", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStarLists())
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_lists_response.json")
+
+		gock.New(githubAPIURL).
+			Post("/graphql").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_star_lists_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "update_page_lists_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Updated)
+	})
+
+	t.Run("replaces the commas that notion doesn't allow in the list names", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithStarLists())
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_lists_response.json")
+
+		lists := strings.ReplaceAll(string(loadFixture(t, path.Join("githubapi", "get_star_lists_response.json"))), `"Browser extensions"`, `"Browsers, extensions"`)
+		gock.New(githubAPIURL).
+			Post("/graphql").
+			Reply(200).
+			BodyString(lists)
+
+		// listsMatcher matches the requests that write lists without commas, including the renamed one
+		listsMatcher := bodyMatcher(func(body map[string]any) bool {
+			properties, _ := body["properties"].(map[string]any)
+			property, _ := properties["Lists"].(map[string]any)
+			options, _ := property["multi_select"].([]any)
+
+			renamed := false
+			for _, option := range options {
+				name, _ := option.(map[string]any)["name"].(string)
+				if strings.Contains(name, ",") {
+					return false
+				}
+
+				renamed = renamed || name == "Browsers; extensions"
+			}
+
+			return renamed
+		})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			AddMatcher(listsMatcher).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b").
+			AddMatcher(listsMatcher).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Updated)
+	})
+
+	t.Run("keeps the list memberships in sync on incremental syncs", func(t *testing.T) {
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		require.NoError(t, store.SaveCursor(mockDatabaseID+"/brpaz", state.Cursor{
+			LastSyncAt:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			LastFullSyncAt: time.Now().Add(-time.Hour),
+		}))
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+			syncer.WithNotionRateLimit(0),
+			syncer.WithStateStore(store),
+			syncer.WithStarLists(),
+		)
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_lists_response.json")
+		mockGitHubUser()
+
+		gock.New(githubAPIURL).
+			Post("/graphql").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_star_lists_response.json")))
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.False(t, plan.Full)
+		assert.Equal(t, 2, plan.Count(syncer.ActionCreate))
+		require.Equal(t, 1, plan.Count(syncer.ActionUpdate))
+
+		updated := plan.Changes[2]
+		assert.Equal(t, int64(40733543), updated.RepoID)
+		assert.Equal(t, []string{"Lists"}, updated.Properties)
+	})
+
+	t.Run("syncs the star lists to a relation property", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithListsDatabase(mockListsDatabaseID))
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_list_relation_response.json")
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockListsDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_lists_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockListsDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_lists_database_pages_response.json")))
+
+		gock.New(githubAPIURL).
+			Post("/graphql").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_star_lists_response.json")))
+
+		// only the missing list is created, and only once
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_list_page_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "update_page_list_relation_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+		assert.Equal(t, 1, result.Updated)
+	})
+}
+
+func TestSyncer_Plan_GitHubUser(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	mockNotion := func(t *testing.T) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+	}
+
+	t.Run("fetches the stars of the given github user", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithGitHubUser("octocat"))
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotion(t)
+
+		gock.New(githubAPIURL).
+			Get("/users/octocat/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+	})
+
+	t.Run("waits for the github rate limit to reset before fetching the next page", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithGitHubUser("octocat"))
+		require.NoError(t, err)
+
+		defer gock.Off()
+		mockNotion(t)
+
+		reset := time.Now().Add(2 * time.Second)
+
+		gock.New(githubAPIURL).
+			Get("/users/octocat/starred").
+			MatchParam("page", "2").
+			Reply(200).
+			JSON([]any{})
+
+		gock.New(githubAPIURL).
+			Get("/users/octocat/starred").
+			Reply(200).
+			SetHeader("Link", `<https://api.github.com/users/octocat/starred?page=2>; rel="next"`).
+			SetHeader("X-RateLimit-Limit", "60").
+			SetHeader("X-RateLimit-Remaining", "0").
+			SetHeader("X-RateLimit-Reset", fmt.Sprint(reset.Unix())).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		assert.False(t, time.Now().Before(time.Unix(reset.Unix(), 0)), "the next page must be fetched after the rate limit reset")
+	})
+}
+
+func TestSyncer_SyncStars_MultipleAccounts(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	newSyncer := func(t *testing.T) *syncer.Syncer {
+		t.Helper()
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+			syncer.WithNotionRateLimit(0),
+			syncer.WithGitHubUser("alice"),
+			syncer.WithGitHubAccount(github.NewClient(nil), "bob"),
+		)
+		require.NoError(t, err)
+
+		return syncerSvc
+	}
+
+	mockNotionAndGitHub := func(t *testing.T, databaseResponse string) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", databaseResponse)))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/users/alice/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/users/bob/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_second_account_response.json")))
+	}
+
+	t.Run("should return error if an additional github client is nil", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithGitHubAccount(nil, "bob"))
+
+		assert.Equal(t, syncer.ErrNilGithubClient, err)
+		assert.Nil(t, syncerSvc)
+	})
+
+	t.Run("should return error if notion database has no starred by property", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		_, err := newSyncer(t).Plan(context.Background(), mockDatabaseID)
+
+		assert.ErrorContains(t, err, "notion database is missing required property Starred by")
+	})
+
+	t.Run("only archives the pages of repos that no account stars", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_starred_by_response.json")
+
+		plan, err := newSyncer(t).Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 0, plan.Count(syncer.ActionArchive))
+		require.Equal(t, 1, plan.Count(syncer.ActionUpdate))
+
+		updated := plan.Changes[3]
+		assert.Equal(t, "nostr-rs-relay", updated.Name)
+		assert.Equal(t, []string{"Starred by"}, updated.Properties)
+	})
+
+	t.Run("stores the accounts that starred each repo", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_starred_by_response.json")
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_starred_by_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			BodyString(`{"properties":{"Starred by":{"multi_select":[{"name":"bob"}]}},"archived":false}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := newSyncer(t).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 1, result.Updated)
+	})
+}
+
+func TestCreateDatabase(t *testing.T) {
+	mockParentPageID := "c6a2e8f0-4b1d-4e7a-9c3f-5d8b2a1e6f90"
+
+	t.Run("should return error if parent page id is not set", func(t *testing.T) {
+		databaseID, err := syncer.CreateDatabase(context.Background(), notionapi.NewClient(""), syncer.DatabaseOptions{})
+
+		assert.Equal(t, syncer.ErrParentPageIDRequired, err)
+		assert.Empty(t, databaseID)
+	})
+
+	t.Run("should return error if property mapping is invalid", func(t *testing.T) {
+		_, err := syncer.CreateDatabase(context.Background(), notionapi.NewClient(""), syncer.DatabaseOptions{
+			ParentPageID: mockParentPageID,
+			Mapping:      syncer.PropertyMapping{syncer.FieldDescription: "Topics"},
+		})
+
+		assert.ErrorContains(t, err, "invalid property mapping")
+	})
+
+	t.Run("creates a database with the required properties", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Post("/v1/databases").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_database_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		databaseID, err := syncer.CreateDatabase(context.Background(), notionapi.NewClient(""), syncer.DatabaseOptions{
+			ParentPageID: mockParentPageID,
+			StarredBy:    true,
+		})
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, "705baa92-0ea9-4a4f-bb97-4916d1cb45bc", databaseID)
+	})
+}
+
+func TestSyncer_Diagnose(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	checkStatuses := func(diagnosis *syncer.Diagnosis) map[string]syncer.CheckStatus {
+		statuses := make(map[string]syncer.CheckStatus, len(diagnosis.Checks))
+		for _, check := range diagnosis.Checks {
+			statuses[check.Name] = check.Status
+		}
+
+		return statuses
+	}
+
+	t.Run("reports a healthy setup", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+		require.NoError(t, err)
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get("/v1/users/me").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_current_user_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/rate_limit").
+			Reply(200).
+			SetHeader("X-OAuth-Scopes", "repo, read:user").
+			JSON(loadFixture(t, path.Join("githubapi", "get_rate_limit_response.json")))
+
+		diagnosis := syncerSvc.Diagnose(context.Background(), mockDatabaseID)
+
+		assert.True(t, diagnosis.Healthy())
+		assert.True(t, gock.IsDone())
+		assert.Empty(t, diagnosis.SchemaProblems)
+		assert.Equal(t, map[string]syncer.CheckStatus{
+			"notion token":           syncer.CheckOK,
+			"notion database":        syncer.CheckOK,
+			"notion database schema": syncer.CheckOK,
+			"github token":           syncer.CheckOK,
+		}, checkStatuses(diagnosis))
+	})
+
+	t.Run("reports all the problems at once", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+			syncer.WithNotionRateLimit(0),
+			syncer.WithStarLists(),
+			syncer.WithPropertyMapping(syncer.PropertyMapping{
+				syncer.FieldRepoURL:  "Link",
+				syncer.FieldLanguage: "Repository URL",
+			}),
+		)
+		require.NoError(t, err)
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get("/v1/users/me").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_current_user_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/rate_limit").
+			Reply(200).
+			SetHeader("X-OAuth-Scopes", "repo").
+			JSON(loadFixture(t, path.Join("githubapi", "get_rate_limit_response.json")))
+
+		diagnosis := syncerSvc.Diagnose(context.Background(), mockDatabaseID)
+
+		assert.False(t, diagnosis.Healthy())
+		assert.True(t, gock.IsDone())
+		assert.ElementsMatch(t, []syncer.SchemaProblem{
+			{Field: syncer.FieldLanguage, Property: "Repository URL", ExpectedType: notionapi.PropertyTypeSelect, ActualType: notionapi.PropertyTypeURL},
+			{Field: syncer.FieldRepoURL, Property: "Link", ExpectedType: notionapi.PropertyTypeURL},
+			{Field: syncer.FieldLists, Property: "Lists", ExpectedType: notionapi.PropertyTypeMultiSelect},
+		}, diagnosis.SchemaProblems)
+		assert.Equal(t, map[string]syncer.CheckStatus{
+			"notion token":           syncer.CheckOK,
+			"notion database":        syncer.CheckOK,
+			"notion database schema": syncer.CheckFailed,
+			"github token":           syncer.CheckFailed,
+		}, checkStatuses(diagnosis))
+	})
+
+	t.Run("reports invalid notion credentials", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+		require.NoError(t, err)
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get("/v1/users/me").
+			Reply(401).
+			JSON(loadFixture(t, path.Join("notionapi", "invalid_credentials_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/rate_limit").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_rate_limit_response.json")))
+
+		diagnosis := syncerSvc.Diagnose(context.Background(), mockDatabaseID)
+
+		assert.False(t, diagnosis.Healthy())
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, map[string]syncer.CheckStatus{
+			"notion token": syncer.CheckFailed,
+			"github token": syncer.CheckOK,
+		}, checkStatuses(diagnosis))
+	})
+}
+
+func TestSyncer_FixSchema(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	t.Run("adds and converts the properties of the database", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+		require.NoError(t, err)
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			BodyString(string(loadFixture(t, path.Join("notionapi", "update_database_schema_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		err = syncerSvc.FixSchema(context.Background(), mockDatabaseID, []syncer.SchemaProblem{
+			{Field: syncer.FieldRepoURL, Property: "Link", ExpectedType: notionapi.PropertyTypeURL},
+			{Field: syncer.FieldLanguage, Property: "Repository URL", ExpectedType: notionapi.PropertyTypeSelect, ActualType: notionapi.PropertyTypeURL},
+		})
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("should return error if the title property is missing", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+		require.NoError(t, err)
+
+		err = syncerSvc.FixSchema(context.Background(), mockDatabaseID, []syncer.SchemaProblem{
+			{Field: syncer.FieldTitle, Property: "Repo", ExpectedType: notionapi.PropertyTypeTitle},
+		})
+
+		assert.ErrorContains(t, err, "property Repo can't be fixed automatically")
+	})
+}
+
+func TestSyncer_SyncStars_Readme(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	mockPageID := "f0a80e0c-0d3f-4c1f-8c7f-396eb8aebf7b"
+
+	// childrenMatcher matches the requests whose body holds the given number of children blocks
+	childrenMatcher := func(count int) gock.MatchFunc {
+		return func(req *http.Request, _ *gock.Request) (bool, error) {
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return false, err
+			}
+
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			var request struct {
+				Children []json.RawMessage `json:"children"`
+			}
+			if err := json.Unmarshal(body, &request); err != nil {
+				return false, err
+			}
+
+			return len(request.Children) == count, nil
+		}
+	}
+
+	t.Run("creates the pages with the readme of their repo", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithReadme())
+		require.NoError(t, err)
+
+		defer gock.Off()
+
+		// the readme has more blocks than notion accepts in a single request
+		var readme strings.Builder
+		for i := 0; i < 105; i++ {
+			fmt.Fprintf(&readme, "Paragraph %d\n\n", i)
+		}
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/repos/aklinker1/vite-plugin-web-extension/readme").
+			Reply(200).
+			JSON(map[string]any{
+				"type":         "file",
+				"encoding":     "base64",
+				"name":         "README.md",
+				"path":         "README.md",
+				"content":      base64.StdEncoding.EncodeToString([]byte(readme.String())),
+				"html_url":     "https://github.com/aklinker1/vite-plugin-web-extension/blob/main/README.md",
+				"download_url": "https://raw.githubusercontent.com/aklinker1/vite-plugin-web-extension/main/README.md",
+			})
+
+		gock.New(githubAPIURL).
+			Get("/repos/JGeek00/adguard-home-manager/readme").
+			Reply(404).
+			JSON(map[string]string{"message": "Not Found"})
+
+		gock.New(githubAPIURL).
+			Get("/repos/mdn/webextensions-examples/readme").
+			Reply(404).
+			JSON(map[string]string{"message": "Not Found"})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			AddMatcher(childrenMatcher(100)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/blocks/%s/children", mockPageID)).
+			AddMatcher(childrenMatcher(5)).
+			Reply(200).
+			JSON(map[string]any{"object": "list", "results": []any{}})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_2_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_3_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 0, result.Failed)
+	})
+
+	t.Run("creates the pages without the readme when it can't be fetched or added", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0), syncer.WithReadme())
+		require.NoError(t, err)
+
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/repos/aklinker1/vite-plugin-web-extension/readme").
+			Reply(200).
+			JSON(map[string]any{
+				"type":     "file",
+				"encoding": "base64",
+				"name":     "README.md",
+				"path":     "README.md",
+				"content":  base64.StdEncoding.EncodeToString([]byte("# Vite plugin\n")),
+			})
+
+		// a secondary rate limit of github
+		gock.New(githubAPIURL).
+			Get("/repos/JGeek00/adguard-home-manager/readme").
+			Reply(403).
+			JSON(map[string]string{"message": "You have exceeded a secondary rate limit"})
+
+		gock.New(githubAPIURL).
+			Get("/repos/mdn/webextensions-examples/readme").
+			Reply(404).
+			JSON(map[string]string{"message": "Not Found"})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			AddMatcher(childrenMatcher(1)).
+			Reply(400).
+			JSON(map[string]any{"object": "error", "status": 400, "code": "validation_error", "message": "body failed validation"})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_1_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_2_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			BodyString(string(loadFixture(t, path.Join("notionapi", "create_page_3_request.json")))).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 0, result.Failed)
+	})
+}
+
+func TestSyncer_SyncStars_UnstarPolicy(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	mockArchiveDatabaseID := "b3f1c2d4-5e6f-4a7b-8c9d-0e1f2a3b4c5d"
+	mockPageID := "9ef240ab-18de-4808-92ee-22f6dce028e9"
+
+	newSyncer := func(t *testing.T, opts ...syncer.Option) *syncer.Syncer {
+		t.Helper()
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), append([]syncer.Option{syncer.WithNotionRateLimit(0)}, opts...)...)
+		require.NoError(t, err)
+
+		return syncerSvc
+	}
+
+	mockNotionAndGitHub := func(t *testing.T, databaseResponse string, pagesResponse string) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", databaseResponse)))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", pagesResponse)))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+	}
+
+	t.Run("should return error if the unstar policy is invalid", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithUnstarPolicy("delete"))
+
+		assert.Equal(t, syncer.ErrInvalidUnstarPolicy, err)
+		assert.Nil(t, syncerSvc)
+	})
+
+	t.Run("should return error if pages are moved without an archive database", func(t *testing.T) {
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithUnstarPolicy(syncer.UnstarMove))
+
+		assert.Equal(t, syncer.ErrArchiveDatabaseRequired, err)
+		assert.Nil(t, syncerSvc)
+	})
+
+	t.Run("should return error if notion database has no unstarred properties", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		_, err := newSyncer(t, syncer.WithUnstarPolicy(syncer.UnstarMark)).Plan(context.Background(), mockDatabaseID)
+
+		assert.ErrorContains(t, err, "notion database is missing required property Unstarred")
+	})
+
+	t.Run("keeps the pages of unstarred repos", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", "get_database_pages_response.json")
+
+		plan, err := newSyncer(t, syncer.WithUnstarPolicy(syncer.UnstarKeep)).Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 0, plan.Count(syncer.ActionArchive))
+		assert.Len(t, plan.Changes, 3)
+	})
+
+	t.Run("never touches protected pages", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", "get_database_pages_protected_response.json")
+
+		plan, err := newSyncer(t).Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 0, plan.Count(syncer.ActionArchive))
+		assert.Len(t, plan.Changes, 3)
+		assert.Equal(t, 1, plan.Skipped)
+	})
+
+	t.Run("marks the pages of unstarred repos", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_unstarred_response.json", "get_database_pages_response.json")
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				properties, _ := body["properties"].(map[string]any)
+				unstarred, _ := properties["Unstarred"].(map[string]any)
+				unstarredAt, _ := properties["Unstarred at"].(map[string]any)
+
+				return unstarred["checkbox"] == true && unstarredAt["date"] != nil && body["archived"] == false
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := newSyncer(t, syncer.WithUnstarPolicy(syncer.UnstarMark)).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 1, result.Marked)
+		assert.Equal(t, 0, result.Archived)
+	})
+
+	t.Run("moves the pages of unstarred repos to the archive database", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", "get_database_pages_response.json")
+
+		var pages struct {
+			Results []json.RawMessage `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")), &pages))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockArchiveDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				return body["parent"].(map[string]any)["database_id"] == mockDatabaseID
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			Reply(200).
+			JSON(pages.Results[0])
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", mockPageID)).
+			Reply(200).
+			JSON(map[string]any{
+				"object":   "list",
+				"has_more": false,
+				"results": []any{
+					map[string]any{
+						"object":       "block",
+						"id":           "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e",
+						"type":         "paragraph",
+						"has_children": false,
+						"paragraph": map[string]any{
+							"rich_text": []any{map[string]any{"type": "text", "text": map[string]any{"content": "Our notes"}}},
+						},
+					},
+				},
+			})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				properties, _ := body["properties"].(map[string]any)
+				children, _ := body["children"].([]any)
+				if body["parent"].(map[string]any)["database_id"] != mockArchiveDatabaseID || properties["Name"] == nil || len(children) != 1 {
+					return false
+				}
+
+				paragraph := children[0].(map[string]any)
+				_, hasID := paragraph["id"]
+
+				return paragraph["type"] == "paragraph" && !hasID
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		mockGitHubUser()
+
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		defer store.Close()
+
+		syncerSvc := newSyncer(t, syncer.WithUnstarPolicy(syncer.UnstarMove), syncer.WithArchiveDatabase(mockArchiveDatabaseID), syncer.WithStateStore(store))
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 1, result.Moved)
+		assert.Equal(t, 0, result.Archived)
+
+		// the copy is recorded, so that it is removed if the repo is starred again
+		records, err := store.Repos()
+		require.NoError(t, err)
+		assert.Equal(t, mockPageID, records[431715396].PageID)
+		assert.Equal(t, "f0a80e0c-0d3f-4c1f-8c7f-396eb8aebf7b", records[431715396].CopyID)
+	})
+
+	t.Run("copies the nested blocks that don't fit in a single request", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", "get_database_pages_response.json")
+
+		var pages struct {
+			Results []json.RawMessage `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")), &pages))
+
+		itemID := "4d5e6f7a-8b9c-4d0e-1f2a-3b4c5d6e7f8a"
+		copiedItemID := "5e6f7a8b-9c0d-4e1f-2a3b-4c5d6e7f8a9b"
+		archivedPageID := "f0a80e0c-0d3f-4c1f-8c7f-396eb8aebf7b"
+
+		// the list item has more children than notion accepts in a single request
+		paragraphs := make([]any, 0, 120)
+		for i := 0; i < 120; i++ {
+			paragraphs = append(paragraphs, map[string]any{
+				"object":       "block",
+				"id":           fmt.Sprintf("00000000-0000-4000-8000-%012d", i),
+				"type":         "paragraph",
+				"has_children": false,
+				"paragraph": map[string]any{
+					"rich_text": []any{map[string]any{"type": "text", "text": map[string]any{"content": fmt.Sprintf("Note %d", i)}}},
+				},
+			})
+		}
+
+		// childrenCount returns the number of children of the given block of a request body
+		childrenCount := func(block map[string]any) int {
+			content, _ := block[block["type"].(string)].(map[string]any)
+			children, _ := content["children"].([]any)
+			return len(children)
+		}
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockArchiveDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				return body["parent"].(map[string]any)["database_id"] == mockDatabaseID
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			Reply(200).
+			JSON(pages.Results[0])
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", mockPageID)).
+			Reply(200).
+			JSON(map[string]any{
+				"object":   "list",
+				"has_more": false,
+				"results": []any{
+					map[string]any{
+						"object":             "block",
+						"id":                 itemID,
+						"type":               "bulleted_list_item",
+						"has_children":       true,
+						"bulleted_list_item": map[string]any{"rich_text": []any{map[string]any{"type": "text", "text": map[string]any{"content": "Notes"}}}},
+					},
+				},
+			})
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", itemID)).
+			Reply(200).
+			JSON(map[string]any{"object": "list", "has_more": false, "results": paragraphs})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				children, _ := body["children"].([]any)
+				return body["parent"].(map[string]any)["database_id"] == mockArchiveDatabaseID && len(children) == 1 && childrenCount(children[0].(map[string]any)) == 100
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", archivedPageID)).
+			Reply(200).
+			JSON(map[string]any{
+				"object":   "list",
+				"has_more": false,
+				"results": []any{
+					map[string]any{
+						"object":             "block",
+						"id":                 copiedItemID,
+						"type":               "bulleted_list_item",
+						"has_children":       true,
+						"bulleted_list_item": map[string]any{"rich_text": []any{}},
+					},
+				},
+			})
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/blocks/%s/children", copiedItemID)).
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				children, _ := body["children"].([]any)
+				return len(children) == 20
+			})).
+			Reply(200).
+			JSON(map[string]any{"object": "list", "results": []any{}})
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		syncerSvc := newSyncer(t, syncer.WithUnstarPolicy(syncer.UnstarMove), syncer.WithArchiveDatabase(mockArchiveDatabaseID))
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, result.Moved)
+	})
+
+	t.Run("archives the partial copy when the move fails", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", "get_database_pages_response.json")
+
+		var pages struct {
+			Results []json.RawMessage `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")), &pages))
+
+		itemID := "4d5e6f7a-8b9c-4d0e-1f2a-3b4c5d6e7f8a"
+		copiedItemID := "5e6f7a8b-9c0d-4e1f-2a3b-4c5d6e7f8a9b"
+		archivedPageID := "f0a80e0c-0d3f-4c1f-8c7f-396eb8aebf7b"
+
+		// the list item has more children than notion accepts in a single request
+		paragraphs := make([]any, 0, 120)
+		for i := 0; i < 120; i++ {
+			paragraphs = append(paragraphs, map[string]any{
+				"object":       "block",
+				"id":           fmt.Sprintf("00000000-0000-4000-8000-%012d", i),
+				"type":         "paragraph",
+				"has_children": false,
+				"paragraph": map[string]any{
+					"rich_text": []any{map[string]any{"type": "text", "text": map[string]any{"content": fmt.Sprintf("Note %d", i)}}},
+				},
+			})
+		}
+
+		// childrenCount returns the number of children of the given block of a request body
+		childrenCount := func(block map[string]any) int {
+			content, _ := block[block["type"].(string)].(map[string]any)
+			children, _ := content["children"].([]any)
+			return len(children)
+		}
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockArchiveDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				return body["parent"].(map[string]any)["database_id"] == mockDatabaseID
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			Reply(200).
+			JSON(pages.Results[0])
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", mockPageID)).
+			Reply(200).
+			JSON(map[string]any{
+				"object":   "list",
+				"has_more": false,
+				"results": []any{
+					map[string]any{
+						"object":             "block",
+						"id":                 itemID,
+						"type":               "bulleted_list_item",
+						"has_children":       true,
+						"bulleted_list_item": map[string]any{"rich_text": []any{map[string]any{"type": "text", "text": map[string]any{"content": "Notes"}}}},
+					},
+				},
+			})
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", itemID)).
+			Reply(200).
+			JSON(map[string]any{"object": "list", "has_more": false, "results": paragraphs})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				children, _ := body["children"].([]any)
+				return body["parent"].(map[string]any)["database_id"] == mockArchiveDatabaseID && len(children) == 1 && childrenCount(children[0].(map[string]any)) == 100
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", archivedPageID)).
+			Reply(200).
+			JSON(map[string]any{
+				"object":   "list",
+				"has_more": false,
+				"results": []any{
+					map[string]any{
+						"object":             "block",
+						"id":                 copiedItemID,
+						"type":               "bulleted_list_item",
+						"has_children":       true,
+						"bulleted_list_item": map[string]any{"rich_text": []any{}},
+					},
+				},
+			})
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/blocks/%s/children", copiedItemID)).
+			Reply(400).
+			JSON(map[string]any{"object": "error", "status": 400, "code": "validation_error", "message": "body failed validation"})
+
+		// the copy is archived, and the original page is kept
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", archivedPageID)).
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		syncerSvc := newSyncer(t, syncer.WithUnstarPolicy(syncer.UnstarMove), syncer.WithArchiveDatabase(mockArchiveDatabaseID))
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.Error(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 0, result.Moved)
+		assert.Equal(t, 1, result.Failed)
+	})
+
+	t.Run("does not move the pages with content that can't be copied", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", "get_database_pages_response.json")
+
+		var pages struct {
+			Results []json.RawMessage `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")), &pages))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockArchiveDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				return body["parent"].(map[string]any)["database_id"] == mockDatabaseID
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			Reply(200).
+			JSON(pages.Results[0])
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", mockPageID)).
+			Reply(200).
+			JSON(map[string]any{
+				"object":   "list",
+				"has_more": false,
+				"results": []any{
+					map[string]any{
+						"object":       "block",
+						"id":           "3c4d5e6f-7a8b-4c9d-0e1f-2a3b4c5d6e7f",
+						"type":         "child_page",
+						"has_children": false,
+						"child_page":   map[string]any{"title": "Sub page"},
+					},
+					map[string]any{
+						"object":       "block",
+						"id":           "6f7a8b9c-0d1e-4f2a-3b4c-5d6e7f8a9b0c",
+						"type":         "image",
+						"has_children": false,
+						"image": map[string]any{
+							"type": "file",
+							"file": map[string]any{"url": "https://files.notion.so/screenshot.png", "expiry_time": "2024-01-01T00:00:00.000Z"},
+						},
+					},
+				},
+			})
+
+		syncerSvc := newSyncer(t, syncer.WithUnstarPolicy(syncer.UnstarMove), syncer.WithArchiveDatabase(mockArchiveDatabaseID))
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.Error(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 0, result.Moved)
+		assert.Equal(t, 1, result.Failed)
+	})
+}
+
+func TestSyncer_Dedupe(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	newerPageID := "9ef240ab-18de-4808-92ee-22f6dce028e9"
+	olderPageID := "c7d8e9f0-1a2b-4c3d-8e4f-5a6b7c8d9e0f"
+
+	syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+	require.NoError(t, err)
+
+	mockNotion := func(t *testing.T) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_duplicates_response.json")))
+	}
+
+	t.Run("reports the duplicate pages when syncing", func(t *testing.T) {
+		defer gock.Off()
+		mockNotion(t)
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		require.Len(t, plan.Duplicates, 1)
+		assert.Equal(t, int64(431715396), plan.Duplicates[0].RepoID)
+		assert.Equal(t, []string{newerPageID, olderPageID}, plan.Duplicates[0].PageIDs)
+	})
+
+	t.Run("should return error if the keep strategy is invalid", func(t *testing.T) {
+		_, err := syncerSvc.PlanDedupe(context.Background(), mockDatabaseID, "newest")
+
+		assert.Equal(t, syncer.ErrInvalidKeepStrategy, err)
+	})
+
+	t.Run("keeps the oldest page and merges the user properties of the others", func(t *testing.T) {
+		defer gock.Off()
+		mockNotion(t)
+
+		plan, err := syncerSvc.PlanDedupe(context.Background(), mockDatabaseID, syncer.KeepOldest)
+
+		require.NoError(t, err)
+		require.Len(t, plan.Duplicates, 1)
+
+		duplicate := plan.Duplicates[0]
+		assert.Equal(t, olderPageID, duplicate.KeepPageID)
+		assert.Equal(t, []string{newerPageID}, duplicate.ArchivePageIDs)
+		assert.Equal(t, []string{"Notes", "Tags"}, duplicate.MergedProperties)
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", olderPageID)).
+			BodyString(`{"properties":{"Notes":{"type":"rich_text","rich_text":[{"type":"text","text":{"content":"Great relay"},"annotations":{"bold":false,"italic":false,"strikethrough":false,"underline":false,"code":false,"color":"default"},"plain_text":"Great relay"}]},"Tags":{"multi_select":[{"name":"favorite"},{"name":"self-hosted"}]}},"archived":false}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", newerPageID)).
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.Dedupe(context.Background(), plan)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, result.Updated)
+		assert.Equal(t, 1, result.Archived)
+	})
+
+	t.Run("keeps the page with the most content", func(t *testing.T) {
+		defer gock.Off()
+		mockNotion(t)
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", olderPageID)).
+			Reply(200).
+			JSON(map[string]any{"object": "list", "results": []any{}, "has_more": false})
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/blocks/%s/children", newerPageID)).
+			Reply(200).
+			JSON(map[string]any{
+				"object":   "list",
+				"has_more": false,
+				"results": []any{
+					map[string]any{
+						"object":    "block",
+						"id":        "2b3c4d5e-6f7a-4b8c-9d0e-1f2a3b4c5d6e",
+						"type":      "paragraph",
+						"paragraph": map[string]any{"rich_text": []any{}},
+					},
+				},
+			})
+
+		plan, err := syncerSvc.PlanDedupe(context.Background(), mockDatabaseID, syncer.KeepMostContent)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		require.Len(t, plan.Duplicates, 1)
+		assert.Equal(t, newerPageID, plan.Duplicates[0].KeepPageID)
+		assert.Equal(t, []string{olderPageID}, plan.Duplicates[0].ArchivePageIDs)
+		assert.Equal(t, []string{"Tags"}, plan.Duplicates[0].MergedProperties)
+	})
+
+	// protectedPages returns the duplicate pages with the older page protected, along with the extra given pages
+	protectedPages := func(t *testing.T, extra ...map[string]any) map[string]any {
+		t.Helper()
+
+		var pages map[string]any
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("notionapi", "get_database_pages_duplicates_response.json")), &pages))
+
+		results := pages["results"].([]any)
+		older := results[1].(map[string]any)
+		older["properties"].(map[string]any)["Protected"] = map[string]any{"id": "Prt%3D", "type": "checkbox", "checkbox": true}
+
+		for _, page := range extra {
+			results = append(results, page)
+		}
+		pages["results"] = results
+
+		return pages
+	}
+
+	mockProtectedNotion := func(t *testing.T, pages map[string]any) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(pages)
+	}
+
+	t.Run("leaves the protected pages out of the merge", func(t *testing.T) {
+		defer gock.Off()
+
+		// a copy of the newer page, created afterwards
+		var pages struct {
+			Results []map[string]any `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("notionapi", "get_database_pages_duplicates_response.json")), &pages))
+
+		copied := pages.Results[0]
+		copiedPageID := "d8e9f0a1-2b3c-4d4e-9f5a-6b7c8d9e0f1a"
+		copied["id"] = copiedPageID
+		copied["created_time"] = "2023-12-28T09:00:00.000Z"
+
+		mockProtectedNotion(t, protectedPages(t, copied))
+
+		plan, err := syncerSvc.PlanDedupe(context.Background(), mockDatabaseID, syncer.KeepOldest)
+
+		require.NoError(t, err)
+		require.Len(t, plan.Duplicates, 1)
+
+		// the protected page is the oldest one, but it is neither kept nor merged into the kept page
+		duplicate := plan.Duplicates[0]
+		assert.Equal(t, newerPageID, duplicate.KeepPageID)
+		assert.Equal(t, []string{copiedPageID}, duplicate.ArchivePageIDs)
+		assert.Equal(t, []string{olderPageID}, duplicate.ProtectedPageIDs)
+		assert.Empty(t, duplicate.MergedProperties)
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", copiedPageID)).
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.Dedupe(context.Background(), plan)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 0, result.Updated)
+		assert.Equal(t, 1, result.Archived)
+	})
+
+	t.Run("doesn't merge the repos with a single page that isn't protected", func(t *testing.T) {
+		defer gock.Off()
+		mockProtectedNotion(t, protectedPages(t))
+
+		plan, err := syncerSvc.PlanDedupe(context.Background(), mockDatabaseID, syncer.KeepOldest)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Empty(t, plan.Duplicates)
+	})
+}
+
+func TestSyncer_SyncStars_MalformedPages(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+	require.NoError(t, err)
+
+	t.Run("reports the malformed pages and only archives the pages created by the sync", func(t *testing.T) {
+		defer gock.Off()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_malformed_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 1, plan.Skipped)
+
+		require.Equal(t, 1, plan.Count(syncer.ActionArchive))
+		assert.Equal(t, "9ef240ab-18de-4808-92ee-22f6dce028e9", plan.Changes[3].PageID)
+
+		assert.Equal(t, []syncer.MalformedPage{
+			{
+				PageID:  "d1e2f3a4-b5c6-4d7e-8f9a-0b1c2d3e4f5a",
+				PageURL: "https://www.notion.so/d1e2f3a4b5c64d7e8f9a0b1c2d3e4f5a",
+				Reason:  "property Repository ID has no valid repository id",
+			},
+			{
+				PageID:  "e2f3a4b5-c6d7-4e8f-9a0b-1c2d3e4f5a6b",
+				PageURL: "https://www.notion.so/e2f3a4b5c6d74e8f9a0b1c2d3e4f5a6b",
+				Reason:  "property Repository ID is missing",
+			},
+		}, plan.Malformed)
+	})
+}
+
+func TestSyncer_SyncStars_AdoptPages(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	mockURLPageID := "a7c3e1f2-4b5d-4e6f-8a9b-0c1d2e3f4a5b"
+	mockTitlePageID := "b8d4f2a3-5c6e-4f7a-9b0c-1d2e3f4a5b6c"
+
+	mockNotionAndGitHub := func(t *testing.T) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_unidentified_response.json")))
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+	}
+
+	// repoIDMatcher matches the requests that write the given repository id to a page
+	repoIDMatcher := func(repoID float64) gock.MatchFunc {
+		return bodyMatcher(func(body map[string]any) bool {
+			properties, _ := body["properties"].(map[string]any)
+			property, _ := properties["Repository ID"].(map[string]any)
+
+			return property["number"] == repoID
+		})
+	}
+
+	syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), syncer.WithNotionRateLimit(0))
+	require.NoError(t, err)
+
+	t.Run("plans to adopt the pages that match a starred repo by url or title", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		plan, err := syncerSvc.Plan(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, plan.Count(syncer.ActionCreate))
+		assert.Equal(t, 2, plan.Count(syncer.ActionAdopt))
+		assert.Equal(t, 1, plan.Count(syncer.ActionArchive))
+		assert.Empty(t, plan.Malformed)
+
+		adoptedByURL := plan.Changes[1]
+		assert.Equal(t, syncer.ActionAdopt, adoptedByURL.Action)
+		assert.Equal(t, "vite-plugin-web-extension", adoptedByURL.Name)
+		assert.Equal(t, mockURLPageID, adoptedByURL.PageID)
+		assert.Contains(t, adoptedByURL.Properties, "Repository ID")
+		assert.Contains(t, adoptedByURL.Properties, "Name")
+
+		adoptedByTitle := plan.Changes[2]
+		assert.Equal(t, syncer.ActionAdopt, adoptedByTitle.Action)
+		assert.Equal(t, "webextensions-examples", adoptedByTitle.Name)
+		assert.Equal(t, mockTitlePageID, adoptedByTitle.PageID)
+		assert.Contains(t, adoptedByTitle.Properties, "Repository URL")
+	})
+
+	t.Run("writes the repository id to the adopted pages", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockURLPageID)).
+			AddMatcher(repoIDMatcher(423249811)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockTitlePageID)).
+			AddMatcher(repoIDMatcher(40733543)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := syncerSvc.SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, result.Created)
+		assert.Equal(t, 2, result.Adopted)
+		assert.Equal(t, 1, result.Archived)
+		assert.Empty(t, result.Malformed)
+	})
+}
+
+func TestParseDeleteLimit(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected syncer.DeleteLimit
+		err      error
+	}{
+		{value: "10", expected: syncer.DeleteLimit{Pages: 10}},
+		{value: "0", expected: syncer.DeleteLimit{}},
+		{value: "5%", expected: syncer.DeleteLimit{Percent: 5}},
+		{value: "2.5 %", expected: syncer.DeleteLimit{Percent: 2.5}},
+		{value: "-1", err: syncer.ErrInvalidDeleteLimit},
+		{value: "150%", err: syncer.ErrInvalidDeleteLimit},
+		{value: "many", err: syncer.ErrInvalidDeleteLimit},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			limit, err := syncer.ParseDeleteLimit(tc.value)
+
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.expected, limit)
+		})
+	}
+}
+
+func TestSyncer_SyncStars_DeleteLimit(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+
+	mockNotion := func(t *testing.T) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_response.json")))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "get_database_pages_response.json")))
+	}
+
+	mockNotionAndGitHub := func(t *testing.T) {
+		t.Helper()
+		mockNotion(t)
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")))
+	}
+
+	newSyncer := func(t *testing.T, limit syncer.DeleteLimit) *syncer.Syncer {
+		t.Helper()
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""),
+			syncer.WithNotionRateLimit(0),
+			syncer.WithDeleteLimit(limit),
+		)
+		require.NoError(t, err)
+
+		return syncerSvc
+	}
+
+	t.Run("refuses to apply a plan that removes more pages than the limit", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		result, err := newSyncer(t, syncer.DeleteLimit{Pages: 0}).SyncStars(context.Background(), mockDatabaseID)
+
+		var limitErr *syncer.DeleteLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Nil(t, result)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, limitErr.Deletions)
+		assert.Equal(t, 1, limitErr.Existing)
+		assert.Equal(t, 3, limitErr.Plan.Count(syncer.ActionCreate))
+		assert.EqualError(t, err, "the sync would remove 1 of 1 pages, which exceeds the delete limit of 0")
+	})
+
+	t.Run("refuses a full sync without starred repos whatever the limit", func(t *testing.T) {
+		defer gock.Off()
+		mockNotion(t)
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON([]any{})
+
+		result, err := newSyncer(t, syncer.DeleteLimit{Percent: 100}).SyncStars(context.Background(), mockDatabaseID)
+
+		var limitErr *syncer.DeleteLimitError
+		require.ErrorAs(t, err, &limitErr)
+		assert.Nil(t, result)
+		assert.True(t, gock.IsDone())
+		assert.True(t, limitErr.NoStars)
+		assert.EqualError(t, err, "the sync found no starred repos and would remove 1 of 1 pages")
+	})
+
+	t.Run("applies a plan within the limit", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(3).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch("/v1/pages/9ef240ab-18de-4808-92ee-22f6dce028e9").
+			BodyString(`{"properties":null,"archived":true}`).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		// 5% of a single page rounds down to zero, but a percentage always allows one page
+		result, err := newSyncer(t, syncer.DeleteLimit{Percent: 5}).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 3, result.Created)
+		assert.Equal(t, 1, result.Archived)
+	})
+}
+
+func TestDeleteLimit_Max(t *testing.T) {
+	tests := []struct {
+		limit    syncer.DeleteLimit
+		existing int
+		expected int
+	}{
+		{limit: syncer.DeleteLimit{Pages: 10}, existing: 5, expected: 10},
+		{limit: syncer.DeleteLimit{Pages: 0}, existing: 5, expected: 0},
+		{limit: syncer.DeleteLimit{Percent: 10}, existing: 200, expected: 20},
+		{limit: syncer.DeleteLimit{Percent: 10}, existing: 25, expected: 2},
+		{limit: syncer.DeleteLimit{Percent: 5}, existing: 10, expected: 1},
+		{limit: syncer.DeleteLimit{Percent: 5}, existing: 0, expected: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s of %d pages", tc.limit, tc.existing), func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.limit.Max(tc.existing))
+		})
+	}
+}
+
+func TestSyncer_SyncStars_OptionalProperties(t *testing.T) {
+	mockDatabaseID := "705baa92-0ea9-4a4f-bb97-4916d1cb45bc"
+	mockPageID := "b5c1e2f4-6a7d-4e3b-9f1a-2c8d7e6f5a4b"
+
+	// property returns a function that reads the value written to the given property of a request body, from the
+	// field of its type. Without a property name, the value is read from the request body itself.
+	property := func(name string, read func(property map[string]any) any) func(body map[string]any) any {
+		return func(body map[string]any) any {
+			if name == "" {
+				return read(body)
+			}
+
+			properties, _ := body["properties"].(map[string]any)
+			value, ok := properties[name].(map[string]any)
+			if !ok {
+				return nil
+			}
+
+			return read(value)
+		}
+	}
+
+	number := func(property map[string]any) any { return property["number"] }
+	checkbox := func(property map[string]any) any { return property["checkbox"] }
+	date := func(property map[string]any) any {
+		value, _ := property["date"].(map[string]any)
+		return value["start"]
+	}
+	richText := func(property map[string]any) any {
+		texts, _ := property["rich_text"].([]any)
+		if len(texts) != 1 {
+			return nil
+		}
+
+		return texts[0].(map[string]any)["text"].(map[string]any)["content"]
+	}
+	selectName := func(property map[string]any) any {
+		value, _ := property["select"].(map[string]any)
+		return value["name"]
+	}
+	avatar := func(body map[string]any) any {
+		value, _ := body["icon"].(map[string]any)
+		external, _ := value["external"].(map[string]any)
+		return external["url"]
+	}
+
+	// valueMatcher matches the requests that write the given value, read by the given function
+	valueMatcher := func(value func(body map[string]any) any, expected any) gock.MatchFunc {
+		return bodyMatcher(func(body map[string]any) bool {
+			return value(body) == expected
+		})
+	}
+
+	// archivedStarredRepos returns the starred repos, with the repo of the existing page archived on github
+	archivedStarredRepos := func(t *testing.T) []map[string]any {
+		t.Helper()
+
+		var stars []map[string]any
+		require.NoError(t, json.Unmarshal(loadFixture(t, path.Join("githubapi", "get_starred_repos_response.json")), &stars))
+
+		for _, star := range stars {
+			if repo, _ := star["repo"].(map[string]any); repo["id"] == float64(40733543) {
+				repo["archived"] = true
+			}
+		}
+
+		return stars
+	}
+
+	// mockNotionAndGitHub mocks the requests that read the given database and pages, and the starred repos
+	mockNotionAndGitHub := func(t *testing.T, databaseResponse string, pages any) {
+		t.Helper()
+
+		gock.New(notionAPIURL).
+			Get(fmt.Sprintf("/v1/databases/%s", mockDatabaseID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", databaseResponse)))
+
+		gock.New(notionAPIURL).
+			Post(fmt.Sprintf("/v1/databases/%s/query", mockDatabaseID)).
+			Reply(200).
+			JSON(pages)
+
+		gock.New(githubAPIURL).
+			Get("/user/starred").
+			Reply(200).
+			JSON(archivedStarredRepos(t))
+	}
+
+	// captureLogs redirects the logs to a buffer until the test ends
+	captureLogs := func(t *testing.T) *bytes.Buffer {
+		t.Helper()
+
+		logs := &bytes.Buffer{}
+		previous := slog.Default()
+		slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+		t.Cleanup(func() { slog.SetDefault(previous) })
+
+		return logs
+	}
+
+	newSyncer := func(t *testing.T, opts ...syncer.Option) *syncer.Syncer {
+		t.Helper()
+
+		syncerSvc, err := syncer.New(github.NewClient(nil), notionapi.NewClient(""), append([]syncer.Option{syncer.WithNotionRateLimit(0)}, opts...)...)
+		require.NoError(t, err)
+
+		return syncerSvc
+	}
+
+	outdatedPages := loadFixture(t, path.Join("notionapi", "get_database_pages_outdated_response.json"))
+
+	tests := []struct {
+		name string
+		// property is the name of the written property, or empty when the value is not a property
+		property string
+		read     func(property map[string]any) any
+		// created holds the values written to the pages created for vite-plugin-web-extension and adguard-home-manager
+		created []any
+		// updated is the value written to the existing page of webextensions-examples, or nil when it's up to date
+		updated any
+	}{
+		{name: "stars", property: "Stars", read: number, created: []any{401.0, 179.0}, updated: 3853.0},
+		{name: "forks", property: "Forks", read: number, created: []any{38.0, 12.0}, updated: 2638.0},
+		{name: "open issues", property: "Open issues", read: number, created: []any{13.0, 11.0}, updated: 14.0},
+		{name: "archived", property: "Archived", read: checkbox, created: []any{false, false}, updated: true},
+		{name: "fork", property: "Fork", read: checkbox, created: []any{false, false}},
+		{name: "template", property: "Template", read: checkbox, created: []any{false, false}},
+		{name: "pushed at", property: "Pushed at", read: date, created: []any{"2023-12-27T18:57:19Z", "2023-12-20T17:23:56Z"}, updated: "2023-11-04T17:16:54Z"},
+		{name: "repository created at", property: "Repository created at", read: date, created: []any{"2021-10-31T20:09:08Z", "2022-09-26T11:53:20Z"}, updated: "2015-08-14T19:55:54Z"},
+		{name: "repository updated at", property: "Repository updated at", read: date, created: []any{"2024-01-07T09:45:38Z", "2024-01-05T23:31:48Z"}, updated: "2024-01-06T10:59:42Z"},
+		{name: "starred at", property: "Starred at", read: date, created: []any{"2024-01-06T19:21:51Z", "2024-01-05T23:31:48Z"}, updated: "2023-12-31T15:25:46Z"},
+		{name: "owner", property: "Owner", read: richText, created: []any{"aklinker1", "JGeek00"}, updated: "mdn"},
+		{name: "owner type", property: "Owner type", read: selectName, created: []any{"User", "User"}, updated: "Organization"},
+		{name: "owner avatar as icon", read: avatar, created: []any{"https://avatars.githubusercontent.com/u/10101283?v=4", "https://avatars.githubusercontent.com/u/47545344?v=4"}, updated: "https://avatars.githubusercontent.com/u/7565578?v=4"},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("syncs the %s", tc.name), func(t *testing.T) {
+			defer gock.Off()
+			mockNotionAndGitHub(t, "get_database_with_optional_properties_response.json", outdatedPages)
+
+			value := property(tc.property, tc.read)
+			for _, created := range tc.created {
+				gock.New(notionAPIURL).
+					Post("/v1/pages").
+					AddMatcher(valueMatcher(value, created)).
+					Reply(200).
+					JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+			}
+
+			gock.New(notionAPIURL).
+				Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+				AddMatcher(valueMatcher(value, tc.updated)).
+				Reply(200).
+				JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+			result, err := newSyncer(t).SyncStars(context.Background(), mockDatabaseID)
+
+			require.NoError(t, err)
+			assert.True(t, gock.IsDone())
+			assert.Equal(t, 2, result.Created)
+			assert.Equal(t, 1, result.Updated)
+		})
+	}
+
+	t.Run("ignores the optional properties that don't exist in the database", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", outdatedPages)
+
+		// noOptionalProperties matches the requests that don't write any optional property
+		noOptionalProperties := bodyMatcher(func(body map[string]any) bool {
+			for _, tc := range tests {
+				if tc.property != "" && property(tc.property, tc.read)(body) != nil {
+					return false
+				}
+			}
+
+			return true
+		})
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			AddMatcher(noOptionalProperties).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			AddMatcher(noOptionalProperties).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := newSyncer(t).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 2, result.Created)
+	})
+
+	t.Run("warns about repos archived upstream", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_with_optional_properties_response.json", outdatedPages)
+		logs := captureLogs(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		_, err := newSyncer(t).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Contains(t, logs.String(), `level=WARN msg="repository was archived upstream" repo=webextensions-examples`)
+	})
+
+	t.Run("doesn't warn about the protected pages of repos archived upstream", func(t *testing.T) {
+		defer gock.Off()
+
+		var pages map[string]any
+		require.NoError(t, json.Unmarshal(outdatedPages, &pages))
+		page := pages["results"].([]any)[0].(map[string]any)
+		page["properties"].(map[string]any)["Protected"] = map[string]any{"id": "Prt%3D", "type": "checkbox", "checkbox": true}
+
+		mockNotionAndGitHub(t, "get_database_with_optional_properties_response.json", pages)
+		logs := captureLogs(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := newSyncer(t).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, result.Skipped)
+		assert.NotContains(t, logs.String(), "repository was archived upstream")
+	})
+
+	t.Run("detects repos archived upstream from the state when the database has no archived property", func(t *testing.T) {
+		defer gock.Off()
+		mockNotionAndGitHub(t, "get_database_response.json", outdatedPages)
+		mockGitHubUser()
+		logs := captureLogs(t)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		store, err := state.Open(filepath.Join(t.TempDir(), "state.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+
+		require.NoError(t, store.SaveRepo(state.RepoRecord{
+			RepoID:   40733543,
+			PageID:   mockPageID,
+			Name:     "webextensions-examples",
+			SyncedAt: time.Now(),
+		}))
+
+		_, err = newSyncer(t, syncer.WithStateStore(store), syncer.WithFullSyncInterval(0)).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Contains(t, logs.String(), `level=WARN msg="repository was archived upstream" repo=webextensions-examples`)
+
+		records, err := store.Repos()
+		require.NoError(t, err)
+		assert.True(t, records[40733543].Archived)
+	})
+
+	t.Run("keeps the icons that are not github avatars", func(t *testing.T) {
+		defer gock.Off()
+
+		var pages map[string]any
+		require.NoError(t, json.Unmarshal(outdatedPages, &pages))
+		pages["results"].([]any)[0].(map[string]any)["icon"] = map[string]any{"type": "emoji", "emoji": "🦊"}
+
+		mockNotionAndGitHub(t, "get_database_with_optional_properties_response.json", pages)
+
+		gock.New(notionAPIURL).
+			Post("/v1/pages").
+			Times(2).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		gock.New(notionAPIURL).
+			Patch(fmt.Sprintf("/v1/pages/%s", mockPageID)).
+			AddMatcher(bodyMatcher(func(body map[string]any) bool {
+				_, hasIcon := body["icon"]
+				return !hasIcon && property("Owner", richText)(body) == "mdn"
+			})).
+			Reply(200).
+			JSON(loadFixture(t, path.Join("notionapi", "create_page_response.json")))
+
+		result, err := newSyncer(t).SyncStars(context.Background(), mockDatabaseID)
+
+		require.NoError(t, err)
+		assert.True(t, gock.IsDone())
+		assert.Equal(t, 1, result.Updated)
 	})
 }
@@ -0,0 +1,533 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jomei/notionapi"
+
+	"github.com/brpaz/github-stars-notion-sync/internal/log"
+	"github.com/brpaz/github-stars-notion-sync/internal/markdown"
+)
+
+// UnstarPolicy is what a full sync does to the pages of the repos that are not starred anymore
+type UnstarPolicy string
+
+const (
+	// UnstarArchive archives the pages of unstarred repos
+	UnstarArchive UnstarPolicy = "archive"
+	// UnstarKeep leaves the pages of unstarred repos untouched
+	UnstarKeep UnstarPolicy = "keep"
+	// UnstarMark checks the "unstarred" property of the pages of unstarred repos and sets their "unstarred at" date
+	UnstarMark UnstarPolicy = "mark"
+	// UnstarMove moves the pages of unstarred repos to a separate archive database
+	UnstarMove UnstarPolicy = "move"
+)
+
+var (
+	ErrInvalidUnstarPolicy     = errors.New("unstar policy must be one of: archive, keep, mark, move")
+	ErrArchiveDatabaseRequired = errors.New("an archive database is required to move the pages of unstarred repos")
+)
+
+// maxInlineDepth is the number of levels of nested children accepted by the notion api in a single request.
+// The children nested deeper are appended to their parent block after it is created.
+const maxInlineDepth = 2
+
+// readOnlyBlockFields are the fields of the blocks returned by the notion api that can't be sent when creating them
+var readOnlyBlockFields = []string{"id", "created_time", "last_edited_time", "created_by", "last_edited_by", "has_children", "archived", "in_trash", "parent"}
+
+// uncopyableBlockTypes are the blocks that can't be created through the notion api
+var uncopyableBlockTypes = map[notionapi.BlockType]bool{
+	notionapi.BlockTypeChildPage:     true,
+	notionapi.BlockTypeChildDatabase: true,
+	notionapi.BlockTypeLinkPreview:   true,
+	notionapi.BlockTypeSyncedBlock:   true,
+	notionapi.BlockTypeTemplate:      true,
+	notionapi.BlockTypeUnsupported:   true,
+}
+
+// writablePropertyTypes are the types of the page properties whose values can be copied to another page
+var writablePropertyTypes = map[notionapi.PropertyType]bool{
+	notionapi.PropertyTypeTitle:       true,
+	notionapi.PropertyTypeRichText:    true,
+	notionapi.PropertyTypeNumber:      true,
+	notionapi.PropertyTypeSelect:      true,
+	notionapi.PropertyTypeMultiSelect: true,
+	notionapi.PropertyTypeStatus:      true,
+	notionapi.PropertyTypeDate:        true,
+	notionapi.PropertyTypePeople:      true,
+	notionapi.PropertyTypeCheckbox:    true,
+	notionapi.PropertyTypeURL:         true,
+	notionapi.PropertyTypeEmail:       true,
+	notionapi.PropertyTypePhoneNumber: true,
+	notionapi.PropertyTypeRelation:    true,
+}
+
+// Validate checks that the policy is a known one
+func (p UnstarPolicy) Validate() error {
+	switch p {
+	case UnstarArchive, UnstarKeep, UnstarMark, UnstarMove:
+		return nil
+	default:
+		return ErrInvalidUnstarPolicy
+	}
+}
+
+// unstarChange returns the change that applies the unstar policy to the page of an unstarred repo,
+// or false when the page is left untouched
+func (s *Syncer) unstarChange(page *notionPage) (Change, bool) {
+	change := Change{
+		RepoID: page.GitHubID,
+		Name:   page.Title,
+		URL:    page.URL,
+		PageID: page.ID,
+		page:   page,
+	}
+
+	switch s.unstarPolicy {
+	case UnstarKeep:
+		return Change{}, false
+	case UnstarMark:
+		// the page was already marked by a previous sync
+		if page.Unstarred {
+			return Change{}, false
+		}
+
+		change.Action = ActionMark
+		change.request = &notionapi.PageUpdateRequest{
+			Properties: notionapi.Properties{
+				s.mapping.Name(FieldUnstarred):   &notionapi.CheckboxProperty{Checkbox: true},
+				s.mapping.Name(FieldUnstarredAt): buildDateProperty(time.Now()),
+			},
+		}
+		change.Properties = propertyNames(change.request.Properties)
+	case UnstarMove:
+		change.Action = ActionMove
+	default:
+		change.Action = ActionArchive
+	}
+
+	return change, true
+}
+
+// archiveDatabase holds the schema of the database where the pages of unstarred repos are moved
+type archiveDatabase struct {
+	id            notionapi.DatabaseID
+	titleProperty string
+	properties    map[string]notionapi.PropertyType
+}
+
+// loadArchiveDatabase fetches the schema of the database where the pages of unstarred repos are moved
+func (s *Syncer) loadArchiveDatabase(ctx context.Context) (*archiveDatabase, error) {
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	database, err := s.notion.Database.Get(ctx, s.archiveDatabaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &archiveDatabase{
+		id:         s.archiveDatabaseID,
+		properties: make(map[string]notionapi.PropertyType, len(database.Properties)),
+	}
+
+	for name, property := range database.Properties {
+		propertyType := notionapi.PropertyType(property.GetType())
+		archive.properties[name] = propertyType
+
+		if propertyType == notionapi.PropertyTypeTitle {
+			archive.titleProperty = name
+		}
+	}
+
+	if archive.titleProperty == "" {
+		return nil, errors.New("archive database has no title property")
+	}
+
+	return archive, nil
+}
+
+// moveNotionPage copies the page to the archive database, with its properties and its content, and archives it.
+// Notion doesn't allow to change the database of a page, so the copy gets a new id.
+// The page is not moved when some of its content can't be copied, like the files uploaded to notion.
+// It returns the id of the copy.
+func (s *Syncer) moveNotionPage(ctx context.Context, archive *archiveDatabase, pageID notionapi.PageID) (string, error) {
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	page, err := s.notion.Page.Get(ctx, pageID)
+	if err != nil {
+		return "", err
+	}
+
+	blocks, dropped, err := s.copyBlocks(ctx, notionapi.BlockID(pageID))
+	if err != nil {
+		return "", fmt.Errorf("error reading the content of the page: %w", err)
+	}
+
+	// files uploaded to notion can't be attached to another page through the api
+	if page.Icon != nil && page.Icon.File != nil {
+		dropped = append(dropped, droppedBlock{id: pageID.String(), blockType: "icon", reason: "uploaded file"})
+	}
+
+	if page.Cover != nil && page.Cover.File != nil {
+		dropped = append(dropped, droppedBlock{id: pageID.String(), blockType: "cover", reason: "uploaded file"})
+	}
+
+	if len(dropped) > 0 {
+		for _, block := range dropped {
+			log.Warn(ctx, "content of the page can't be copied to the archive database",
+				log.String("page", pageID.String()),
+				log.String("block", block.id),
+				log.String("type", block.blockType),
+				log.String("reason", block.reason),
+			)
+		}
+
+		return "", fmt.Errorf("%d blocks of the page can't be copied to the archive database, move it by hand", len(dropped))
+	}
+
+	first := inlineBlocks(blocks[:min(len(blocks), markdown.MaxBlocksPerRequest)], 0)
+	request := &notionapi.PageCreateRequest{
+		Parent: notionapi.Parent{
+			Type:       notionapi.ParentTypeDatabaseID,
+			DatabaseID: archive.id,
+		},
+		Properties: archive.copyProperties(page.Properties),
+		Children:   toBlocks(first),
+		Icon:       page.Icon,
+		Cover:      page.Cover,
+	}
+
+	if err := s.notionLimiter.Wait(ctx); err != nil {
+		return "", err
+	}
+
+	archivedPage, err := s.notion.Page.Create(ctx, request)
+	if err != nil {
+		return "", fmt.Errorf("error copying the page to the archive database: %w", err)
+	}
+
+	if err := s.completeMove(ctx, notionapi.PageID(archivedPage.ID), pageID, blocks, first); err != nil {
+		// the copy is archived, so that the next sync moves the page again instead of leaving a partial duplicate
+		// in the archive database
+		if archiveErr := s.deleteNotionPage(ctx, notionapi.PageID(archivedPage.ID)); archiveErr != nil {
+			log.Error(ctx, "error archiving the partial copy of the page, remove it by hand",
+				log.String("page", pageID.String()),
+				log.String("copy", archivedPage.ID.String()),
+				log.String("error", archiveErr.Error()),
+			)
+		}
+
+		return "", err
+	}
+
+	return archivedPage.ID.String(), nil
+}
+
+// completeMove copies the content of the page that didn't fit in the request that created its copy, and archives
+// the page once its copy is complete
+func (s *Syncer) completeMove(ctx context.Context, copyID notionapi.PageID, pageID notionapi.PageID, blocks []copiedBlock, first []copiedBlock) error {
+	if err := s.appendRemainingChildren(ctx, notionapi.BlockID(copyID), first); err != nil {
+		return fmt.Errorf("error copying the content of the page to the archive database: %w", err)
+	}
+
+	if err := s.appendCopiedBlocks(ctx, notionapi.BlockID(copyID), blocks[len(first):]); err != nil {
+		return fmt.Errorf("error copying the content of the page to the archive database: %w", err)
+	}
+
+	return s.deleteNotionPage(ctx, pageID)
+}
+
+// copyProperties returns the properties of a page that can be written to the archive database, which are the
+// writable properties whose name and type match. The title is always copied, even if its name is different.
+func (a *archiveDatabase) copyProperties(properties notionapi.Properties) notionapi.Properties {
+	copied := make(notionapi.Properties, len(properties))
+
+	for name, property := range properties {
+		propertyType := property.GetType()
+		if !writablePropertyTypes[propertyType] {
+			continue
+		}
+
+		if propertyType == notionapi.PropertyTypeTitle {
+			copied[a.titleProperty] = copiedProperty{property}
+			continue
+		}
+
+		if a.properties[name] == propertyType {
+			copied[name] = copiedProperty{property}
+		}
+	}
+
+	return copied
+}
+
+// droppedBlock is a block of a page that can't be copied to another page
+type droppedBlock struct {
+	id        string
+	blockType string
+	reason    string
+}
+
+// copyBlocks reads the blocks of the given parent, with all their children, so that they can be written to another
+// page. It also returns the blocks that can't be copied.
+func (s *Syncer) copyBlocks(ctx context.Context, parentID notionapi.BlockID) ([]copiedBlock, []droppedBlock, error) {
+	children, err := s.childBlocks(ctx, parentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blocks := make([]copiedBlock, 0, len(children))
+	dropped := make([]droppedBlock, 0)
+
+	for _, block := range children {
+		if uncopyableBlockTypes[block.GetType()] {
+			dropped = append(dropped, droppedBlock{id: block.GetID().String(), blockType: string(block.GetType()), reason: "not supported by the notion api"})
+			continue
+		}
+
+		if isUploadedFile(block) {
+			dropped = append(dropped, droppedBlock{id: block.GetID().String(), blockType: string(block.GetType()), reason: "uploaded file"})
+			continue
+		}
+
+		copied := copiedBlock{Block: block}
+		if block.GetHasChildren() {
+			var droppedChildren []droppedBlock
+			copied.children, droppedChildren, err = s.copyBlocks(ctx, block.GetID())
+			if err != nil {
+				return nil, nil, err
+			}
+
+			dropped = append(dropped, droppedChildren...)
+		}
+
+		blocks = append(blocks, copied)
+	}
+
+	return blocks, dropped, nil
+}
+
+// childBlocks reads all the children of the given parent
+func (s *Syncer) childBlocks(ctx context.Context, parentID notionapi.BlockID) ([]notionapi.Block, error) {
+	blocks := make([]notionapi.Block, 0)
+
+	cursor := notionapi.Cursor("")
+	for {
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := s.notion.Block.GetChildren(ctx, parentID, &notionapi.Pagination{
+			StartCursor: cursor,
+			PageSize:    markdown.MaxBlocksPerRequest,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, resp.Results...)
+
+		if !resp.HasMore {
+			break
+		}
+
+		cursor = notionapi.Cursor(resp.NextCursor)
+	}
+
+	return blocks, nil
+}
+
+// appendCopiedBlocks appends the copied blocks to the parent, in batches of the maximum size accepted by notion
+func (s *Syncer) appendCopiedBlocks(ctx context.Context, parentID notionapi.BlockID, blocks []copiedBlock) error {
+	for len(blocks) > 0 {
+		batch := inlineBlocks(blocks[:min(len(blocks), markdown.MaxBlocksPerRequest)], 0)
+		blocks = blocks[len(batch):]
+
+		if err := s.notionLimiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		resp, err := s.notion.Block.AppendChildren(ctx, parentID, &notionapi.AppendBlockChildrenRequest{
+			Children: toBlocks(batch),
+		})
+		if err != nil {
+			return err
+		}
+
+		if hasRemainingChildren(batch) {
+			// notion returns the appended blocks, in the order they were sent
+			if len(resp.Results) != len(batch) {
+				return fmt.Errorf("expected %d appended blocks in %s, got %d", len(batch), parentID, len(resp.Results))
+			}
+
+			if err := s.completeBlocks(ctx, batch, resp.Results); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// appendRemainingChildren appends the children that were not sent with the blocks created in a new parent,
+// because they are nested too deep or there are too many of them
+func (s *Syncer) appendRemainingChildren(ctx context.Context, parentID notionapi.BlockID, created []copiedBlock) error {
+	if !hasRemainingChildren(created) {
+		return nil
+	}
+
+	children, err := s.childBlocks(ctx, parentID)
+	if err != nil {
+		return err
+	}
+
+	if len(children) != len(created) {
+		return fmt.Errorf("expected %d blocks in %s, found %d", len(created), parentID, len(children))
+	}
+
+	return s.completeBlocks(ctx, created, children)
+}
+
+// completeBlocks appends the children that were not sent with the created blocks to their copies
+func (s *Syncer) completeBlocks(ctx context.Context, created []copiedBlock, copies []notionapi.Block) error {
+	for i, block := range created {
+		if err := s.appendRemainingChildren(ctx, copies[i].GetID(), block.inline); err != nil {
+			return err
+		}
+
+		if err := s.appendCopiedBlocks(ctx, copies[i].GetID(), block.children[len(block.inline):]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inlineBlocks returns copies of the blocks that send their children in the same request, up to the nesting and
+// the number of children accepted by the notion api. The level is the nesting of the blocks in the request.
+func inlineBlocks(blocks []copiedBlock, level int) []copiedBlock {
+	inlined := make([]copiedBlock, len(blocks))
+	for i, block := range blocks {
+		inlined[i] = copiedBlock{Block: block.Block, children: block.children}
+		if level < maxInlineDepth {
+			inlined[i].inline = inlineBlocks(block.children[:min(len(block.children), markdown.MaxBlocksPerRequest)], level+1)
+		}
+	}
+
+	return inlined
+}
+
+// hasRemainingChildren tells if some children of the blocks are not sent with them
+func hasRemainingChildren(blocks []copiedBlock) bool {
+	for _, block := range blocks {
+		if len(block.inline) < len(block.children) || hasRemainingChildren(block.inline) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toBlocks(copied []copiedBlock) []notionapi.Block {
+	blocks := make([]notionapi.Block, len(copied))
+	for i := range copied {
+		blocks[i] = copied[i]
+	}
+
+	return blocks
+}
+
+// isUploadedFile tells if the block holds a file uploaded to notion, which can't be attached to another page
+// through the api
+func isUploadedFile(block notionapi.Block) bool {
+	switch b := block.(type) {
+	case *notionapi.ImageBlock:
+		return b.Image.File != nil
+	case *notionapi.VideoBlock:
+		return b.Video.File != nil
+	case *notionapi.FileBlock:
+		return b.File.File != nil
+	case *notionapi.PdfBlock:
+		return b.Pdf.File != nil
+	case *notionapi.AudioBlock:
+		return b.Audio.File != nil
+	default:
+		return false
+	}
+}
+
+// copiedProperty is a property read from a page that is written to another page.
+// Its id is removed, since property ids are different in each database.
+type copiedProperty struct {
+	notionapi.Property
+}
+
+func (p copiedProperty) MarshalJSON() ([]byte, error) {
+	return marshalWithout(p.Property, "id")
+}
+
+// copiedBlock is a block read from a page that is written to another page.
+// Its read-only fields are removed, and the children sent with it, which are not returned with the block, are added.
+type copiedBlock struct {
+	notionapi.Block
+	children []copiedBlock
+	// inline holds the children sent in the same request as the block
+	inline []copiedBlock
+}
+
+func (b copiedBlock) MarshalJSON() ([]byte, error) {
+	data, err := marshalWithout(b.Block, readOnlyBlockFields...)
+	if err != nil || len(b.inline) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	// the children are nested in the object that holds the content of the block, like "paragraph"
+	blockType := string(b.GetType())
+
+	var content map[string]json.RawMessage
+	if err := json.Unmarshal(fields[blockType], &content); err != nil {
+		return nil, err
+	}
+
+	if content["children"], err = json.Marshal(b.inline); err != nil {
+		return nil, err
+	}
+
+	if fields[blockType], err = json.Marshal(content); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+// marshalWithout serializes the value as a JSON object, without the given fields
+func marshalWithout(value any, fields ...string) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	for _, field := range fields {
+		delete(object, field)
+	}
+
+	return json.Marshal(object)
+}